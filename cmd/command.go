@@ -0,0 +1,82 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/x-research-team/kernel/internal/config"
+)
+
+// command Выполнить служебную команду вместо запуска ядра
+//
+//	config print            вывести эффективную конфигурацию с источником значений
+//	config validate         проверить файлы конфигурации по схемам
+//	config keygen           создать ключ шифрования секретов
+//	config encrypt <value>  зашифровать значение в ссылку ${enc:...}
+func command(dir, profile string, args ...string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch {
+	case len(args) == 2 && args[0] == "config" && args[1] == "print":
+		c, err := config.Load(dir, profile)
+		if err != nil {
+			log.Fatalf("[SYS] %v\n", err)
+		}
+		if err := c.Print(os.Stdout); err != nil {
+			log.Fatalf("[SYS] %v\n", err)
+		}
+	case len(args) == 2 && args[0] == "config" && args[1] == "validate":
+		if _, err := config.Load(dir, profile); err != nil {
+			errs, ok := err.(config.TErrors)
+			if !ok {
+				log.Fatalf("[SYS] %v\n", err)
+			}
+			for _, e := range errs {
+				fmt.Fprintln(os.Stderr, config.Redact(e.Error()))
+			}
+			os.Exit(1)
+		}
+		fmt.Println("configuration is valid")
+	case len(args) == 2 && args[0] == "config" && args[1] == "keygen":
+		keyring := config.Keyring(dir)
+		if err := keyring.Generate(); err != nil {
+			log.Fatalf("[SYS] %v\n", err)
+		}
+		fmt.Println(keyring.Path)
+	case len(args) == 3 && args[0] == "config" && args[1] == "encrypt":
+		value, err := config.Keyring(dir).Encrypt(args[2])
+		if err != nil {
+			log.Fatalf("[SYS] %v\n", err)
+		}
+		fmt.Println(value)
+	default:
+		log.Fatalf("[SYS] unknown command: %v\n", strings.Join(args, " "))
+	}
+	return true
+}
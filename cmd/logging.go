@@ -0,0 +1,62 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/x-research-team/kernel/external/system/storage/component/dialect"
+	"github.com/x-research-team/kernel/external/system/storage/component/dsn"
+	"github.com/x-research-team/kernel/internal/config"
+)
+
+// journal Базы данных соединений mongo для приемника журнала логов
+func journal(c *config.TKernelConfig) func(connection string) (*mongo.Database, error) {
+	return func(connection string) (*mongo.Database, error) {
+		db, ok := c.Databases[connection]
+		if !ok {
+			return nil, fmt.Errorf("connection (%v) not found", connection)
+		}
+		connections, err := dsn.Parse(config.TDataBaseConfigs{connection: db})
+		if err != nil {
+			return nil, err
+		}
+		v := connections[connection]
+		if v.GetDialect() != dialect.Mongo+"db" {
+			return nil, fmt.Errorf("connection (%v) is not a journal", connection)
+		}
+		client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(v.GetDSN()).SetAuth(options.Credential{
+			Username: v.GetUser(),
+			Password: v.GetPassword(),
+		}))
+		if err != nil {
+			return nil, err
+		}
+		return client.Database(db.Database), nil
+	}
+}
@@ -24,34 +24,75 @@
 package main
 
 import (
+	"flag"
+	"log"
+	"path/filepath"
+
 	"github.com/x-research-team/bus"
-	"github.com/x-research-team/bus/pipe"
-	"github.com/x-research-team/implant"
+	"github.com/x-research-team/contract"
 	"github.com/x-research-team/kernel/external/system/server"
 	"github.com/x-research-team/kernel/external/system/storage"
 	"github.com/x-research-team/kernel/internal/config"
 	"github.com/x-research-team/kernel/internal/dynamic"
 	"github.com/x-research-team/kernel/internal/kernel"
+	"github.com/x-research-team/kernel/internal/logger"
+	"github.com/x-research-team/kernel/internal/schema"
+	"github.com/x-research-team/kernel/internal/tenant"
 	"github.com/x-research-team/vm"
 )
 
-func init() {
-	// Enable system logging
-	dynamic.Trace(true)
+func main() {
+	// .env.local с секретами загружается первым, и его значения не перекрываются .env
+	for _, path := range []string{".env.local", ".env"} {
+		if err := config.Environment(path); err != nil {
+			log.Fatalf("[SYS] %v\n", err)
+		}
+	}
+	dir := flag.String("config", config.Directory(), "configuration directory (env "+config.DirEnv+")")
+	profile := flag.String("profile", config.Profile(), "configuration profile from <config>/profiles (env "+config.ProfileEnv+")")
+	flag.Parse()
+	if command(*dir, *profile, flag.Args()...) {
+		return
+	}
+	c, err := config.Load(*dir, *profile)
+	if err != nil {
+		log.Fatalf("[SYS] %v\n", err)
+	}
 
 	// Initialize core kernel parts
-	logger := config.Kernel.Log.Level.ToJson()
-	bus.Init(logger)
-	pipe.Init()
+	bus.Init(c.Log.Level.ToJson())
+	logs, err := logger.Open(c.Log, journal(c))
+	if err != nil {
+		log.Fatalf("[SYS] %v\n", err)
+	}
+	logs.Bus()
+	dynamic.TraceTo(logs.Span)
+	if err := dynamic.Tracing(c.Log.Trace); err != nil {
+		bus.Error <- err
+	}
 	vm.Init()
+	if err := kernel.Examples(dynamic.Functions); err != nil {
+		log.Fatalf("[SYS] %v\n", err)
+	}
 
-	components := config.Kernel.Components.Paths()
-	implant.Init(components...)
-}
+	var modules contract.KernelModules
+	for _, component := range c.Components.Enabled() {
+		component.Types = component.Extensions(c.Extensions)
+		modules = append(modules, kernel.Plugin(component))
+	}
 
-func main() {
-	modules := implant.Modules()
-	modules = append(modules, storage.Init(), server.Init())
+	schemas, err := schema.Load(filepath.Join(c.Dir, "schemas"))
+	if err != nil {
+		bus.Error <- err
+	}
+	tenants, err := tenant.Registry(c.Tenants)
+	if err != nil {
+		log.Fatalf("[SYS] %v\n", err)
+	}
+	modules = append(modules, storage.Init(c.Databases, tenants), server.Init(c.Server, schemas, tenants), kernel.Tenants(tenants), kernel.Logger(logs), kernel.Reload(c))
+	if c.Validate {
+		modules = append(modules, kernel.Validate(schemas))
+	}
 	if err := kernel.New(modules...).Run(); err != nil {
 		bus.Error <- err
 	}
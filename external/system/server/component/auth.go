@@ -0,0 +1,67 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package component
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const tenantKey = "tenant"
+
+// outbound Response addressed to the clients of a tenant.
+type outbound struct {
+	id     uuid.UUID // ID of the request the response answers, if any
+	tenant string
+	data   []byte
+}
+
+// token Extract an access token from the request: Authorization bearer,
+// X-Kernel-Token header or the token query parameter (for websockets).
+func token(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimPrefix(h, "Bearer ")
+	}
+	if h := r.Header.Get("X-Kernel-Token"); h != "" {
+		return h
+	}
+	return r.URL.Query().Get("token")
+}
+
+// authenticate Resolve the tenant of the request or reject it.
+func authenticate(component *Component) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		t, ok := component.tenants.Authenticate(token(ctx.Request))
+		if !ok {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, Error(errors.New("UNAUTHORIZED")))
+			return
+		}
+		ctx.Set(tenantKey, t)
+		ctx.Next()
+	}
+}
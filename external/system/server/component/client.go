@@ -70,6 +70,9 @@ type Client struct {
 
 	// Buffered channel of outbound messages.
 	send chan []byte
+
+	// Tenant resolved from the access token on upgrade.
+	tenant string
 }
 
 // readPump pumps messages from the websocket connection to the hub.
@@ -102,7 +105,7 @@ func (c *Client) read() {
 			break
 		}
 		message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
-		c.hub.broadcast <- message
+		c.hub.broadcast <- &inbound{client: c, message: message}
 	}
 }
 
@@ -173,13 +176,13 @@ func (c *Client) write() {
 }
 
 // serveWs handles websocket requests from the peer.
-func tcp(hub *Hub, w http.ResponseWriter, r *http.Request) {
+func tcp(hub *Hub, tenant string, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		bus.Error <- err
 		return
 	}
-	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 1024)}
+	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 1024), tenant: tenant}
 	client.hub.register <- client
 
 	// Allow collection of memory referenced by the caller by doing all work in
@@ -26,12 +26,15 @@ package component
 import (
 	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/gin-gonic/gin"
-
 	"github.com/x-research-team/bus"
 	"github.com/x-research-team/contract"
+	"github.com/x-research-team/kernel/internal/config"
+	"github.com/x-research-team/kernel/internal/schema"
+	"github.com/x-research-team/kernel/internal/tenant"
 
 	"github.com/google/uuid"
 )
@@ -41,16 +44,35 @@ const (
 	route = "server"
 )
 
-type config struct {
-	Timeout time.Duration `json:"timeout"`
+// timeout Ожидание ответа ядра, если в слушателе не задан timeout
+const timeout = 10 * time.Second
+
+// listener Слушатель из server.json и его HTTP сервер
+type listener struct {
+	timeout int64 // Ожидание ответа ядра; меняется на горячем ходу (см. Reconfigure)
+	*config.TListenerConfig
+	server *http.Server
+}
+
+// wait Ожидание ответа ядра
+func (l *listener) wait() time.Duration {
+	return time.Duration(atomic.LoadInt64(&l.timeout))
+}
+
+func (l *listener) setTimeout(d time.Duration) {
+	atomic.StoreInt64(&l.timeout, int64(d))
 }
 
 // Component
 type Component struct {
-	bus chan []byte
-	tcp chan []byte
+	tcp chan *outbound
 
-	config *config
+	m       sync.Mutex
+	waiters map[uuid.UUID]chan *outbound // Запросы GET /api, ожидающие ответа, по ID сообщения
+
+	listeners []*listener
+	schemas   *schema.TRegistry
+	tenants   *tenant.TRegistry
 
 	components map[string]contract.IComponent
 	trunk      contract.ISignalBus
@@ -58,20 +80,17 @@ type Component struct {
 	uuid       string
 	fails      []error
 
-	httpserver *gin.Engine
-	tcpserver  *http.Server
-	socket     *Hub
+	socket *Hub
 }
 
 // New Создать экземпляр компонента сервиса биллинга
 func New(opts ...contract.ComponentModule) contract.KernelModule {
 	component := &Component{
-		bus:        make(chan []byte),
-		tcp:        make(chan []byte),
+		tcp:        make(chan *outbound),
+		waiters:    make(map[uuid.UUID]chan *outbound),
 		components: make(map[string]contract.IComponent),
 		route:      route,
 		trunk:      make(contract.ISignalBus),
-		config:     new(config),
 	}
 	for _, o := range opts {
 		o(component)
@@ -121,13 +140,14 @@ func (component *Component) Run() error {
 	bus.Info <- fmt.Sprintf("[%v] component started", name)
 	component.uuid = uuid.New().String()
 	go component.socket.run()
-	go func () {
-		if err := component.tcpserver.ListenAndServe(); err != nil {
-			bus.Error <- err
-			return
-		}
-	}()
-	return component.httpserver.Run(":43001")
+	errs := make(chan error, len(component.listeners))
+	for _, l := range component.listeners {
+		go func(l *listener) {
+			bus.Info <- fmt.Sprintf("[%v] %v listener on %v", name, l.Type, l.Address())
+			errs <- l.server.ListenAndServe()
+		}(l)
+	}
+	return <-errs
 }
 
 func (component *Component) Route() string { return component.route }
@@ -136,12 +156,36 @@ func (component *Component) Write(message contract.IMessage) error {
 	if message.Route() != component.Route() {
 		return nil
 	}
-	data := message.Data()
-	go func (m string) { component.bus <- []byte(m) }(data)
-	go func (m string) { component.tcp <- []byte(m) }(data)
+	data := &outbound{id: message.ID(), tenant: tenant.Of(message), data: []byte(message.Data())}
+	component.reply(data)
+	go func(o *outbound) { component.tcp <- o }(data)
 	return nil
 }
 
+// wait Зарегистрировать ожидание ответа на сообщение id; release снимает регистрацию
+func (component *Component) wait(id uuid.UUID) (response <-chan *outbound, release func()) {
+	c := make(chan *outbound, 1)
+	component.m.Lock()
+	component.waiters[id] = c
+	component.m.Unlock()
+	return c, func() {
+		component.m.Lock()
+		delete(component.waiters, id)
+		component.m.Unlock()
+	}
+}
+
+// reply Передать ответ запросу, ожидающему его; ответы без ожидающих запросов получают только сокеты
+func (component *Component) reply(o *outbound) {
+	component.m.Lock()
+	c, ok := component.waiters[o.id]
+	delete(component.waiters, o.id)
+	component.m.Unlock()
+	if ok {
+		c <- o
+	}
+}
+
 func (component *Component) Read() string {
 	return ""
 }
@@ -24,32 +24,44 @@
 package component
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"path"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/x-research-team/bus"
 	"github.com/x-research-team/contract"
+	"github.com/x-research-team/kernel/internal/config"
+	"github.com/x-research-team/kernel/internal/schema"
+	"github.com/x-research-team/kernel/internal/tenant"
 )
 
 const JTMP = `{"service":"signal","collection":"messages","filter":{"field":"id","query":"%v"}}`
 
-func configureSocket(component *Component) {
-	component.tcpserver = &http.Server{Addr: ":3000", Handler: nil}
-	component.socket = newHub(&component.trunk, &component.tcp)
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		tcp(component.socket, w, r)
+func configureSocket(component *Component, l *listener) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(path.Join("/", l.Prefix, "ws"), func(w http.ResponseWriter, r *http.Request) {
+		t, ok := component.tenants.Authenticate(token(r))
+		if !ok {
+			http.Error(w, "UNAUTHORIZED", http.StatusUnauthorized)
+			return
+		}
+		tcp(component.socket, t, w, r)
 	})
+	return mux
 }
 
-func configureHttp(component *Component) {
-	component.httpserver = gin.New()
-	component.httpserver.POST("/api", func(ctx *gin.Context) {
+func configureHttp(component *Component, l *listener) http.Handler {
+	engine := gin.New()
+	engine.Use(authenticate(component))
+	api := engine.Group(path.Join("/", l.Prefix))
+	api.POST("/api", func(ctx *gin.Context) {
 		buffer, err := ioutil.ReadAll(ctx.Request.Body)
 		if err != nil {
 			ctx.JSON(http.StatusInternalServerError, Error(err))
@@ -60,62 +72,136 @@ func configureHttp(component *Component) {
 			ctx.JSON(http.StatusBadRequest, Error(err))
 			return
 		}
-		message := bus.Message(m.Route, m.Command, string(m.Message))
+		if err = component.schemas.Validate(m.Route, m.Command, m.Message); err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, Invalid(err))
+			return
+		}
+		t := ctx.GetString(tenantKey)
+		message := tenant.Message(t, bus.Message(m.Route, m.Command, string(m.Message)))
 		go func(m contract.IMessage) { component.trunk <- bus.Signal(m) }(message)
 		ctx.JSON(http.StatusOK, gin.H{"id": message.ID()})
-		response := bus.Message("storage", "journal-store", fmt.Sprintf(JTMP, message.ID()))
+		response := tenant.Message(t, bus.Message("storage", "journal-store", fmt.Sprintf(JTMP, message.ID())))
 		go func(m contract.IMessage) { component.trunk <- bus.Signal(m) }(response)
 	})
-	component.httpserver.GET("/api", func(ctx *gin.Context) {
+	api.GET("/api", func(ctx *gin.Context) {
+		t := ctx.GetString(tenantKey)
 		ids := strings.Split(ctx.Query("id"), ",")
-		message := bus.Message("storage", "journal", fmt.Sprintf(JTMP, ids))
+		message := tenant.Message(t, bus.Message("storage", "journal", fmt.Sprintf(JTMP, ids)))
+		responses, release := component.wait(message.ID())
+		defer release()
 		go func(m contract.IMessage) { component.trunk <- bus.Signal(m) }(message)
-		for {
-			select {
-			case <-time.After(time.Microsecond * component.config.Timeout):
-				ctx.JSON(http.StatusGatewayTimeout, Error(errors.New("gateway timed out")))
-				return
-			case response := <-component.bus:
-				messages := make(JournalMessages, 0)
-				err := json.Unmarshal(response, &messages)
-				switch {
-				case err != nil:
-					ctx.JSON(http.StatusInternalServerError, Error(err))
-					return
-				case messages.IsEmpty():
-					ctx.JSON(http.StatusNotFound, Error(errors.New("NOT_FOUND")))
-					return
-				case messages.IsOne():
-					m := messages[0]
-					ctx.JSON(http.StatusOK, &JournalMessageResponse{
-						ID:   m.ID,
-						Data: m.Data,
-					})
-					return
-				case messages.IsMany():
-					response := make(JournalMessagesResponse, 0)
-					for _, m := range messages {
-						response = append(response, &JournalMessageResponse{
-							ID:   m.ID,
-							Data: m.Data,
-						})
-					}
-					ctx.JSON(http.StatusOK, response)
-					return
-				default:
-					ctx.JSON(http.StatusBadRequest, Error(errors.New("BAD_REQUEST")))
-					return
-				}
-			default:
-				continue
+		deadline := time.NewTimer(l.wait())
+		defer deadline.Stop()
+		var response *outbound
+		select {
+		case <-deadline.C:
+			ctx.JSON(http.StatusGatewayTimeout, Error(errors.New("gateway timed out")))
+			return
+		case response = <-responses:
+		}
+		if response.tenant != t || !IsJournal(response.data) {
+			ctx.JSON(http.StatusBadGateway, Error(errors.New("BAD_GATEWAY")))
+			return
+		}
+		messages := make(JournalMessages, 0)
+		err := json.Unmarshal(response.data, &messages)
+		switch {
+		case err != nil:
+			ctx.JSON(http.StatusInternalServerError, Error(err))
+		case messages.IsEmpty():
+			ctx.JSON(http.StatusNotFound, Error(errors.New("NOT_FOUND")))
+		case messages.IsOne():
+			m := messages[0]
+			ctx.JSON(http.StatusOK, &JournalMessageResponse{
+				ID:   m.ID,
+				Data: m.Data,
+			})
+		case messages.IsMany():
+			response := make(JournalMessagesResponse, 0)
+			for _, m := range messages {
+				response = append(response, &JournalMessageResponse{
+					ID:   m.ID,
+					Data: m.Data,
+				})
 			}
+			ctx.JSON(http.StatusOK, response)
+		default:
+			ctx.JSON(http.StatusBadRequest, Error(errors.New("BAD_REQUEST")))
 		}
 	})
+	return engine
+}
+
+// Validate (registry: *schema.TRegistry) Проверять входящие сообщения по реестру схем
+func Validate(registry *schema.TRegistry) contract.ComponentModule {
+	return func(c contract.IComponent) {
+		c.(*Component).schemas = registry
+	}
+}
+
+// Authenticate (registry: *tenant.TRegistry) Определять арендатора запросов по токену доступа
+func Authenticate(registry *tenant.TRegistry) contract.ComponentModule {
+	return func(c contract.IComponent) {
+		c.(*Component).tenants = registry
+	}
+}
+
+// Listen (listeners: config.TServerConfig) Слушатели сервера из server.json
+func Listen(listeners config.TServerConfig) contract.ComponentModule {
+	return func(c contract.IComponent) {
+		component := c.(*Component)
+		for _, l := range listeners {
+			component.listeners = append(component.listeners, &listener{TListenerConfig: l, timeout: int64(l.Timeout.Or(timeout))})
+		}
+	}
 }
+
+// Reconfigure Применить новое ожидание ответа ядра (timeout) слушателей, адрес и префикс которых
+// не изменились. Таймауты запущенного http.Server не меняются: они требуют перезапуска
+func (component *Component) Reconfigure(c *config.TKernelConfig, changes config.TChanges) []string {
+	applied := make([]string, 0)
+	for i, l := range component.listeners {
+		if i >= len(c.Server) {
+			break
+		}
+		n := c.Server[i]
+		if n.Type != l.Type || n.Address() != l.Address() || n.Prefix != l.Prefix {
+			continue
+		}
+		if key := fmt.Sprintf("server[%d].timeout", i); changes.Has(key) {
+			l.setTimeout(n.Timeout.Or(timeout))
+			applied = append(applied, key)
+		}
+	}
+	return applied
+}
+
 func Configure() contract.ComponentModule {
 	return func(c contract.IComponent) {
-		configureSocket(c.(*Component))
-		configureHttp(c.(*Component))
+		component := c.(*Component)
+		component.socket = newHub(&component.trunk, &component.tcp, component.schemas)
+		if len(component.listeners) == 0 {
+			component.fails = append(component.fails, errors.New("no listeners configured"))
+		}
+		for _, l := range component.listeners {
+			var handler http.Handler
+			switch l.Type {
+			case "http":
+				handler = configureHttp(component, l)
+			case "ws":
+				handler = configureSocket(component, l)
+			default:
+				component.fails = append(component.fails, fmt.Errorf("unknown listener type (%v) on %v", l.Type, l.Address()))
+				continue
+			}
+			l.server = &http.Server{
+				Addr:         l.Address(),
+				Handler:      handler,
+				ReadTimeout:  time.Duration(l.ReadTimeout),
+				WriteTimeout: time.Duration(l.WriteTimeout),
+				IdleTimeout:  time.Duration(l.IdleTimeout),
+			}
+		}
 	}
 }
 
@@ -124,6 +210,15 @@ func Error(err error) gin.H {
 	return gin.H{"error": err.Error()}
 }
 
+// Invalid Ответ с ошибками валидации сообщения
+func Invalid(err error) gin.H {
+	bus.Error <- err
+	if errs, ok := err.(schema.TErrors); ok {
+		return gin.H{"error": "INVALID_MESSAGE", "errors": errs}
+	}
+	return gin.H{"error": err.Error()}
+}
+
 type JournalMessage struct {
 	ID   string          `json:"id"`
 	Data json.RawMessage `json:"data"`
@@ -131,6 +226,12 @@ type JournalMessage struct {
 
 type JournalMessages []JournalMessage
 
+// IsJournal (data: []byte) Является ли ответ выборкой журнала (массивом сообщений), а не ответом ядра
+func IsJournal(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '['
+}
+
 func (m JournalMessages) IsEmpty() bool {
 	return len(m) == 0
 }
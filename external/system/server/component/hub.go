@@ -30,6 +30,8 @@ import (
 
 	"github.com/x-research-team/bus"
 	"github.com/x-research-team/contract"
+	"github.com/x-research-team/kernel/internal/schema"
+	"github.com/x-research-team/kernel/internal/tenant"
 	"github.com/x-research-team/utils/is"
 )
 
@@ -40,7 +42,7 @@ type Hub struct {
 	clients map[*Client]bool
 
 	// Inbound messages from the clients.
-	broadcast chan []byte
+	broadcast chan *inbound
 
 	// Register requests from the clients.
 	register chan *Client
@@ -48,13 +50,20 @@ type Hub struct {
 	// Unregister requests from clients.
 	unregister chan *Client
 
-	trunk *contract.ISignalBus
-	tcp   *chan []byte
+	trunk   *contract.ISignalBus
+	tcp     *chan *outbound
+	schemas *schema.TRegistry
 }
 
-func newHub(trunk *contract.ISignalBus, tcp *chan []byte) *Hub {
+// inbound Message received from a particular client.
+type inbound struct {
+	client  *Client
+	message []byte
+}
+
+func newHub(trunk *contract.ISignalBus, tcp *chan *outbound, schemas *schema.TRegistry) *Hub {
 	return &Hub{
-		broadcast:  make(chan []byte),
+		broadcast:  make(chan *inbound),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		clients:    make(map[*Client]bool),
@@ -74,7 +83,8 @@ func (h *Hub) run() {
 				delete(h.clients, client)
 				close(client.send)
 			}
-		case message := <-h.broadcast:
+		case in := <-h.broadcast:
+			message := in.message
 			if !is.JSON(string(message)) {
 				bus.Error <- fmt.Errorf("error: received message (%s) is not JSON", message)
 				continue
@@ -84,7 +94,13 @@ func (h *Hub) run() {
 				bus.Error <- err
 				continue
 			}
-			msg := bus.Message(km.Route, km.Command, string(km.Message))
+			if err := h.schemas.Validate(km.Route, km.Command, km.Message); err != nil {
+				if err := h.reply(in.client, Invalid(err)); err != nil {
+					bus.Error <- err
+				}
+				continue
+			}
+			msg := tenant.Message(in.client.tenant, bus.Message(km.Route, km.Command, string(km.Message)))
 			*h.trunk <- bus.Signal(msg)
 		}
 	}
@@ -93,30 +109,37 @@ func (h *Hub) run() {
 func (h *Hub) listen() {
 	for {
 		select {
-		case response := <-*h.tcp:
+		case out := <-*h.tcp:
+			t, response := out.tenant, out.data
 			messages := make(JournalMessages, 0)
 			if !is.JSON(string(response)) {
 				bus.Info <- string(response)
 				continue
 			}
+			if !IsJournal(response) {
+				if err := h.send(t, json.RawMessage(response)); err != nil {
+					bus.Error <- err
+				}
+				continue
+			}
 			err := json.Unmarshal(response, &messages)
 			switch {
 			case err != nil:
-				if err := h.fail(err); err != nil {
+				if err := h.fail(t, err); err != nil {
 					bus.Error <- err
 					continue
 				}
 				bus.Error <- err
 				continue
 			case messages.IsEmpty():
-				if err := h.fail(errors.New("EMPTY_RESPONSE")); err != nil {
+				if err := h.fail(t, errors.New("EMPTY_RESPONSE")); err != nil {
 					bus.Error <- err
 					continue
 				}
 				continue
 			case messages.IsOne():
 				m := messages[0]
-				if err := h.send(&JournalMessageResponse{
+				if err := h.send(t, &JournalMessageResponse{
 					ID: m.ID,
 					Data: m.Data,
 				}); err != nil {
@@ -132,13 +155,13 @@ func (h *Hub) listen() {
 						Data: m.Data,
 					})
 				}
-				if err := h.send(response); err != nil {
+				if err := h.send(t, response); err != nil {
 					bus.Error <- err
 					continue
 				}
 				continue
 			default:
-				if err := h.fail(errors.New("BAD_REQUEST")); err != nil {
+				if err := h.fail(t, errors.New("BAD_REQUEST")); err != nil {
 					bus.Error <- err
 					continue
 				}
@@ -149,14 +172,32 @@ func (h *Hub) listen() {
 	}
 }
 
-func (h *Hub) fail(e error) error {
-	if err := h.send(map[string]string{"error": e.Error()}); err != nil {
+func (h *Hub) fail(tenant string, e error) error {
+	if err := h.send(tenant, map[string]string{"error": e.Error()}); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (h *Hub) send(v interface{}) error {
+// reply Send a response to a single client.
+func (h *Hub) reply(client *Client, v interface{}) error {
+	buffer, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, ok := h.clients[client]; !ok {
+		return nil
+	}
+	select {
+	case client.send <- buffer:
+	default:
+		close(client.send)
+		delete(h.clients, client)
+	}
+	return nil
+}
+
+func (h *Hub) send(tenant string, v interface{}) error {
 	var (
 		err    error
 		buffer []byte
@@ -165,6 +206,9 @@ func (h *Hub) send(v interface{}) error {
 		return err
 	}
 	for client := range h.clients {
+		if client.tenant != tenant {
+			continue
+		}
 		select {
 		case client.send <- buffer:
 		default:
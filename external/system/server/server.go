@@ -25,13 +25,19 @@ package server
 
 import (
 	"github.com/x-research-team/kernel/external/system/server/component"
+	"github.com/x-research-team/kernel/internal/config"
+	"github.com/x-research-team/kernel/internal/schema"
+	"github.com/x-research-team/kernel/internal/tenant"
 
 	"github.com/x-research-team/contract"
 )
 
 // Init Load plugin with all components
-func Init() contract.KernelModule {
+func Init(listeners config.TServerConfig, schemas *schema.TRegistry, tenants *tenant.TRegistry) contract.KernelModule {
 	return component.New(
+		component.Listen(listeners),
+		component.Validate(schemas),
+		component.Authenticate(tenants),
 		component.Configure(),
 	)
 }
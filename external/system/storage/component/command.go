@@ -23,6 +23,8 @@
 
 package component
 
+import "encoding/json"
+
 type TCommand struct {
 	Service    string `json:"service"`
 	SQL        string `json:"sql"`
@@ -31,4 +33,12 @@ type TCommand struct {
 		Field string `json:"field"`
 		Query string `json:"query"`
 	} `json:"filter"`
+	Outbox []TOutboxMessage `json:"outbox,omitempty"`
+}
+
+// TOutboxMessage Исходящее сообщение, записываемое в outbox в одной транзакции с SQL
+type TOutboxMessage struct {
+	Route   string          `json:"route"`
+	Command string          `json:"command"`
+	Message json.RawMessage `json:"message"`
 }
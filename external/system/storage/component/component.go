@@ -43,6 +43,8 @@ import (
 
 	"github.com/x-research-team/bus"
 	"github.com/x-research-team/contract"
+	"github.com/x-research-team/kernel/external/system/storage/component/outbox"
+	"github.com/x-research-team/kernel/internal/tenant"
 	"github.com/x-research-team/utils/magic"
 )
 
@@ -60,9 +62,15 @@ type Component struct {
 	route      string
 	uuid       string
 
+	m       sync.RWMutex
 	client  map[string]*sql.DB
+	dialect map[string]string
 	journal map[string]*mongo.Client
 	fails   []error
+
+	relays   map[string]chan struct{} // Каналы остановки доставки outbox по соединениям
+	received *outbox.TDeduplicator
+	tenants  *tenant.TRegistry
 }
 
 // New Создать экземпляр компонента сервиса биллинга
@@ -73,7 +81,10 @@ func New(opts ...contract.ComponentModule) contract.KernelModule {
 		route:      route,
 		trunk:      make(contract.ISignalBus),
 		client:     make(map[string]*sql.DB),
+		dialect:    make(map[string]string),
 		journal:    make(map[string]*mongo.Client),
+		relays:     make(map[string]chan struct{}),
+		received:   outbox.Deduplicator(time.Hour),
 	}
 	for _, o := range opts {
 		o(component)
@@ -115,7 +126,7 @@ func (component *Component) RemovePlugin(name string) error {
 // Configure Конфигурация компонета платежной системы
 func (component *Component) Configure() error {
 	bus.Info <- fmt.Sprintf("[%v] is configured", name)
-	c := component.journal["signal"]
+	c := component.mongo("signal")
 	if c == nil {
 		return errors.New("connection (signal) not found")
 	}
@@ -130,6 +141,16 @@ func (component *Component) Run() error {
 
 	component.uuid = uuid.New().String()
 
+	component.m.RLock()
+	connections := make([]string, 0, len(component.client))
+	for k := range component.client {
+		connections = append(connections, k)
+	}
+	component.m.RUnlock()
+	for _, k := range connections {
+		component.relay(k)
+	}
+
 	var RequestSyncronizer sync.Map
 
 	for {
@@ -164,9 +185,9 @@ func (component *Component) Run() error {
 						for {
 							switch {
 							case ready:
-								if result, err = component.load(command); err != nil {
+								if result, err = component.load(m.Tenant, command); err != nil {
 									bus.Error <- err
-									if err := component.signal(m.ID.String(), nil, err); err != nil {
+									if err := component.signal(m.Tenant, m.ID.String(), nil, err); err != nil {
 										bus.Error <- err
 										continue
 									}
@@ -178,7 +199,7 @@ func (component *Component) Run() error {
 									bus.Error <- err
 									return
 								}
-								component.trunk <- bus.Signal(bus.Message("server", "response", string(buffer)))
+								component.trunk <- bus.Signal(tenant.Message(m.Tenant, bus.Message("server", "response", string(buffer))))
 								return
 							default:
 								time.Sleep(time.Microsecond * 500)
@@ -189,9 +210,9 @@ func (component *Component) Run() error {
 				}(*m)
 				continue
 			case "journal":
-				if result, err = component.load(command); err != nil {
+				if result, err = component.load(m.Tenant, command); err != nil {
 					bus.Error <- err
-					if err := component.signal(m.ID.String(), nil, err); err != nil {
+					if err := component.signal(m.Tenant, m.ID.String(), nil, err); err != nil {
 						bus.Error <- err
 						continue
 					}
@@ -203,28 +224,35 @@ func (component *Component) Run() error {
 					bus.Error <- err
 					continue
 				}
-				component.trunk <- bus.Signal(bus.Message("server", "response", string(buffer)))
+				component.trunk <- bus.Signal(tenant.Message(m.Tenant, &TResponse{id: m.ID, data: string(buffer)}))
+				component.received.Mark(m.ID)
 				continue
 			case "store":
 				RequestSyncronizer.Store(syncID, false)
-				if result, err = component.handle(command); err != nil {
+				if result, err = component.handle(m.Tenant, m.ID, command); err != nil {
+					if errors.Is(err, outbox.ErrDuplicate) {
+						bus.Debug <- fmt.Sprintf("[%v] duplicate message %v skipped", name, m.ID)
+						component.received.Mark(m.ID)
+						continue
+					}
 					bus.Error <- err
-					if err := component.signal(m.ID.String(), nil, err); err != nil {
+					if err := component.signal(m.Tenant, m.ID.String(), nil, err); err != nil {
 						bus.Error <- err
 						continue
 					}
 					continue
 				}
+				component.received.Mark(m.ID)
 			default:
 				err := fmt.Errorf("unknown command (%v)", m.Command)
 				bus.Error <- err
-				if err := component.signal(m.ID.String(), nil, err); err != nil {
+				if err := component.signal(m.Tenant, m.ID.String(), nil, err); err != nil {
 					bus.Error <- err
 					continue
 				}
 				continue
 			}
-			if err := component.signal(m.ID.String(), result, nil); err != nil {
+			if err := component.signal(m.Tenant, m.ID.String(), result, nil); err != nil {
 				bus.Error <- err
 				continue
 			}
@@ -235,8 +263,62 @@ func (component *Component) Run() error {
 	}
 }
 
-func (component *Component) signal(id string, buffer []map[string]interface{}, e error) error {
-	c := component.journal["signal"]
+// deliver Доставить сообщение из outbox в ядро
+func (component *Component) deliver(message contract.IMessage) error {
+	select {
+	case component.trunk <- bus.Signal(message):
+		return nil
+	case <-time.After(time.Second):
+		return errors.New("kernel trunk is busy")
+	}
+}
+
+// database Соединение SQL и его диалект
+func (component *Component) database(k string) (*sql.DB, string) {
+	component.m.RLock()
+	defer component.m.RUnlock()
+	return component.client[k], component.dialect[k]
+}
+
+// mongo Соединение журнала
+func (component *Component) mongo(k string) *mongo.Client {
+	component.m.RLock()
+	defer component.m.RUnlock()
+	return component.journal[k]
+}
+
+// relay Запустить доставку outbox соединения k; доставка, запущенная для k раньше, останавливается
+func (component *Component) relay(k string) {
+	db, d := component.database(k)
+	if db == nil || !outbox.Supported(d) {
+		return
+	}
+	abort := make(chan struct{})
+	component.m.Lock()
+	if previous, ok := component.relays[k]; ok {
+		close(previous)
+	}
+	component.relays[k] = abort
+	component.m.Unlock()
+	go outbox.Relay(db, d).Run(abort, component.deliver)
+}
+
+// halt Остановить доставку outbox всех соединений
+func (component *Component) halt() {
+	component.m.Lock()
+	defer component.m.Unlock()
+	for k, abort := range component.relays {
+		close(abort)
+		delete(component.relays, k)
+	}
+}
+
+func (component *Component) signal(t, id string, buffer []map[string]interface{}, e error) error {
+	connection, ok := component.tenants.Connection(t, "signal")
+	if !ok {
+		return fmt.Errorf("connection (signal) is not mapped for tenant %v", t)
+	}
+	c := component.mongo(connection)
 	if c == nil {
 		return errors.New("connection (signal) not found")
 	}
@@ -310,8 +392,35 @@ func (component *Component) storage(tx *sql.Tx) func(Sqlizer) ([]string, error)
 
 func (component *Component) Route() string { return component.route }
 
+// TResponse Ответ серверу с ID запроса: по нему сервер находит запрос, ожидающий ответа
+type TResponse struct {
+	id   uuid.UUID
+	data string
+}
+
+// ID Correlation ID for kernel message
+func (response TResponse) ID() uuid.UUID {
+	return response.id
+}
+
+// Route Путь сообщения
+func (response TResponse) Route() string {
+	return "server"
+}
+
+// Command Команда которую нужно выполнить с данными в сообщении
+func (response TResponse) Command() string {
+	return "response"
+}
+
+// Data Данные в сообщении сигнала
+func (response TResponse) Data() string {
+	return response.data
+}
+
 type KernelMessage struct {
 	ID      uuid.UUID
+	Tenant  string
 	Command string
 	Data    []byte
 }
@@ -320,9 +429,14 @@ func (component *Component) Write(message contract.IMessage) error {
 	if message.Route() != component.Route() {
 		return nil
 	}
+	if component.received.Seen(message.ID()) {
+		bus.Debug <- fmt.Sprintf("[%v] duplicate message %v skipped", name, message.ID())
+		return nil
+	}
 	bus.Debug <- fmt.Sprintf("%#v", message)
 	buffer, err := json.Marshal(&KernelMessage{
 		ID:      message.ID(),
+		Tenant:  tenant.Of(message),
 		Command: message.Command(),
 		Data:    []byte(message.Data()),
 	})
@@ -350,6 +464,7 @@ func (component *Component) Up(graceful bool) error {
 }
 
 func (component *Component) Down(graceful bool) error {
+	component.halt()
 	return nil
 }
 
@@ -370,10 +485,12 @@ func (component *Component) Cron(rule string) error {
 }
 
 func (component *Component) Stop() error {
+	component.halt()
 	return nil
 }
 
 func (component *Component) Kill() error {
+	component.halt()
 	return nil
 }
 
@@ -385,11 +502,15 @@ func (component *Component) Backup(to string) error {
 	return nil
 }
 
-func (component *Component) load(command *TCommand) ([]map[string]interface{}, error) {
+func (component *Component) load(t string, command *TCommand) ([]map[string]interface{}, error) {
 	if command.Service == "" {
 		return nil, fmt.Errorf("unknown service")
 	}
-	c := component.journal[command.Service]
+	connection, ok := component.tenants.Connection(t, command.Service)
+	if !ok {
+		return nil, fmt.Errorf("connection (%v) is not mapped for tenant %v", command.Service, t)
+	}
+	c := component.mongo(connection)
 	if c == nil {
 		return nil, errors.New("connection not found")
 	}
@@ -409,14 +530,20 @@ func (component *Component) load(command *TCommand) ([]map[string]interface{}, e
 	return results, nil
 }
 
-func (component *Component) handle(command *TCommand) ([]map[string]interface{}, error) {
+// handle Выполнить команду store в транзакции. Сообщение id отмечается в inbox той же транзакцией,
+// поэтому повторная доставка уже выполненной команды возвращает outbox.ErrDuplicate
+func (component *Component) handle(t string, id uuid.UUID, command *TCommand) ([]map[string]interface{}, error) {
 	if command.Service == "" {
 		return nil, fmt.Errorf("unknown service")
 	}
 	if command.SQL == "" {
 		return nil, fmt.Errorf("missing sql raw")
 	}
-	c := component.client[command.Service]
+	connection, ok := component.tenants.Connection(t, command.Service)
+	if !ok {
+		return nil, fmt.Errorf("connection (%v) is not mapped for tenant %v", command.Service, t)
+	}
+	c, d := component.database(connection)
 	if c == nil {
 		return nil, errors.New("connection not found")
 	}
@@ -424,6 +551,18 @@ func (component *Component) handle(command *TCommand) ([]map[string]interface{},
 	if err != nil {
 		return nil, err
 	}
+	if outbox.Supported(d) {
+		duplicate, err := outbox.Received(tx, d, id)
+		if err == nil && duplicate {
+			err = outbox.ErrDuplicate
+		}
+		if err != nil {
+			if err := tx.Rollback(); err != nil {
+				return nil, err
+			}
+			return nil, err
+		}
+	}
 	stmt, err := tx.Prepare(command.SQL)
 	if err != nil {
 		if err := tx.Rollback(); err != nil {
@@ -459,7 +598,12 @@ func (component *Component) handle(command *TCommand) ([]map[string]interface{},
 			}
 			returns = append(returns, m)
 		}
-
+		if err := component.enqueue(t, tx, connection, command); err != nil {
+			if err := tx.Rollback(); err != nil {
+				return nil, err
+			}
+			return nil, err
+		}
 		if err = tx.Commit(); err != nil {
 			return nil, err
 		}
@@ -471,8 +615,30 @@ func (component *Component) handle(command *TCommand) ([]map[string]interface{},
 		}
 		return nil, err
 	}
+	if err := component.enqueue(t, tx, connection, command); err != nil {
+		if err := tx.Rollback(); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
 	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
 	return nil, nil
 }
+
+// enqueue Записать исходящие сообщения команды в outbox текущей транзакции
+func (component *Component) enqueue(t string, tx *sql.Tx, connection string, command *TCommand) error {
+	if len(command.Outbox) == 0 {
+		return nil
+	}
+	_, d := component.database(connection)
+	if !outbox.Supported(d) {
+		return fmt.Errorf("outbox is not supported for connection (%v)", connection)
+	}
+	messages := make([]contract.IMessage, 0, len(command.Outbox))
+	for _, m := range command.Outbox {
+		messages = append(messages, tenant.Message(t, outbox.Message(m.Route, m.Command, string(m.Message))))
+	}
+	return outbox.Enqueue(tx, d, messages...)
+}
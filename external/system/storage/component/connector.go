@@ -26,13 +26,19 @@ package component
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/x-research-team/kernel/external/system/storage/component/dialect"
 	"github.com/x-research-team/kernel/external/system/storage/component/dsn"
+	"github.com/x-research-team/kernel/external/system/storage/component/outbox"
+	"github.com/x-research-team/kernel/internal/tenant"
 
 	"entgo.io/ent/dialect/sql"
+	"github.com/x-research-team/bus"
 	"github.com/x-research-team/contract"
+	"github.com/x-research-team/kernel/internal/config"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
@@ -45,41 +51,99 @@ func ConnectTo(dsn map[string]dsn.IDataBaseConfig) contract.ComponentModule {
 	return func(component contract.IComponent) {
 		c := component.(*Component)
 		for k, v := range dsn {
-			d := v.GetDialect()
-			switch d {
-			case dialect.Mongo + "db":
-				client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(v.GetDSN()).SetAuth(options.Credential{
-					Username: v.GetUser(),
-					Password: v.GetPassword(),
-				}))
-				if err != nil {
-					c.fails = append(c.fails, err)
-					return
-				}
-				c.journal[k] = client
-			case dialect.MySQL,
-				dialect.SQLite,
-				dialect.Postgres,
-				dialect.Gremlin:
-				drv, err := sql.Open(d, v.GetDSN())
-				if err != nil {
-					c.fails = append(c.fails, err)
-					return
-				}
-				db := drv.DB()
-				db.SetMaxIdleConns(10)
-				db.SetMaxOpenConns(100)
-				db.SetConnMaxLifetime(time.Hour)
-				if err = db.Ping(); err != nil {
-					c.fails = append(c.fails, err)
-					return
-				}
-				c.client[k] = db
-			default:
-				c.fails = append(c.fails, errors.New("unsupported dialect"))
+			if err := c.connect(k, v); err != nil {
+				c.fails = append(c.fails, err)
 				return
 			}
 		}
 		component = c
 	}
 }
+
+// connect Открыть соединение k по его конфигурации
+func (c *Component) connect(k string, v dsn.IDataBaseConfig) error {
+	d := v.GetDialect()
+	switch d {
+	case dialect.Mongo + "db":
+		client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(v.GetDSN()).SetAuth(options.Credential{
+			Username: v.GetUser(),
+			Password: v.GetPassword(),
+		}))
+		if err != nil {
+			return err
+		}
+		c.m.Lock()
+		c.journal[k] = client
+		c.m.Unlock()
+	case dialect.MySQL,
+		dialect.SQLite,
+		dialect.Postgres,
+		dialect.Gremlin:
+		drv, err := sql.Open(d, v.GetDSN())
+		if err != nil {
+			return err
+		}
+		db := drv.DB()
+		db.SetMaxIdleConns(10)
+		db.SetMaxOpenConns(100)
+		db.SetConnMaxLifetime(time.Hour)
+		if err = db.Ping(); err != nil {
+			return err
+		}
+		if outbox.Supported(d) {
+			if err = outbox.Migrate(db, d); err != nil {
+				return err
+			}
+		}
+		c.m.Lock()
+		c.client[k] = db
+		c.dialect[k] = d
+		c.m.Unlock()
+	default:
+		return errors.New("unsupported dialect")
+	}
+	return nil
+}
+
+// connected Открыто ли соединение k
+func (c *Component) connected(k string) bool {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	_, client := c.client[k]
+	_, journal := c.journal[k]
+	return client || journal
+}
+
+// Reconfigure Открыть соединения, появившиеся в конфигурации
+func (c *Component) Reconfigure(kernel *config.TKernelConfig, changes config.TChanges) []string {
+	applied := make([]string, 0)
+	connections, err := dsn.Parse(kernel.Databases)
+	if err != nil {
+		bus.Error <- fmt.Errorf("[%v] %v", c.Name(), err)
+	}
+	for name, v := range connections {
+		prefix := "databases." + name + "."
+		if c.connected(name) || !changes.Has(prefix) {
+			continue
+		}
+		if err := c.connect(name, v); err != nil {
+			bus.Error <- fmt.Errorf("[%v] connection (%v): %v", c.Name(), name, err)
+			continue
+		}
+		c.relay(name)
+		for _, change := range changes {
+			if strings.HasPrefix(change.Key, prefix) {
+				applied = append(applied, change.Key)
+			}
+		}
+		bus.Info <- fmt.Sprintf("[%v] connection (%v) opened", c.Name(), name)
+	}
+	return applied
+}
+
+// Tenants (registry: *tenant.TRegistry) Выбирать соединения хранилища по арендатору сообщения
+func Tenants(registry *tenant.TRegistry) contract.ComponentModule {
+	return func(component contract.IComponent) {
+		component.(*Component).tenants = registry
+	}
+}
@@ -24,17 +24,15 @@
 package dsn
 
 import (
+	"errors"
 	"fmt"
-	"path/filepath"
-	"sync"
+	"sort"
+	"strings"
 
-	"github.com/x-research-team/bus"
 	"github.com/x-research-team/kernel/external/system/storage/component/dialect"
-	"github.com/x-research-team/utils/file"
+	"github.com/x-research-team/kernel/internal/config"
 )
 
-var m sync.Mutex
-
 type TDataBaseConfig struct {
 	Name     string `json:"name"`
 	Dialect  string `json:"dialect"`
@@ -112,46 +110,32 @@ func (c TMongoConfig) GetPassword() string {
 	return c.Password
 }
 
-func Parse() map[string]IDataBaseConfig {
+// Parse (configs: config.TDataBaseConfigs) Соединения по конфигурации; соединения
+// с неизвестным диалектом пропускаются и перечисляются в ошибке
+func Parse(configs config.TDataBaseConfigs) (map[string]IDataBaseConfig, error) {
 	v := make(map[string]IDataBaseConfig)
-	dbconfigs, err := filepath.Glob("**/*.dbconfig")
-	if err != nil {
-		return nil
+	unknown := make([]string, 0)
+	for name, c := range configs {
+		base := TDataBaseConfig{Name: c.Name, Dialect: c.Dialect, Database: c.Database}
+		var s IDataBaseConfig
+		switch c.Dialect {
+		case dialect.MySQL:
+			s = &TMySQLConfig{TDataBaseConfig: base, User: c.User, Password: c.Password, Host: c.Host, Port: c.Port}
+		case dialect.SQLite:
+			s = &TSQLiteConfig{TDataBaseConfig: base}
+		case dialect.Postgres:
+			s = &TPostgresConfig{TDataBaseConfig: base}
+		case dialect.Mongo:
+			s = &TMongoConfig{TDataBaseConfig: base, User: c.User, Password: c.Password, Host: c.Host, Port: c.Port}
+		default:
+			unknown = append(unknown, fmt.Sprintf("connection (%v): unknown dialect %q", name, c.Dialect))
+			continue
+		}
+		v[name] = s
 	}
-	var wg sync.WaitGroup
-	wg.Add(len(dbconfigs))
-	for _, dbconfig := range dbconfigs {
-		go func(wg *sync.WaitGroup, dbconfig string) {
-			defer wg.Done()
-			c := new(TDataBaseConfig)
-			if err := file.Read(".", dbconfig, c); err != nil {
-				bus.Error <- err
-				return
-			}
-			if c.Name == "" {
-				bus.Error <- fmt.Errorf("name of connection can not be empty")
-				return
-			}
-			var s IDataBaseConfig
-			switch c.GetDialect() {
-			case dialect.MySQL:
-				s = new(TMySQLConfig)
-			case dialect.SQLite:
-				s = new(TSQLiteConfig)
-			case dialect.Postgres:
-				s = new(TPostgresConfig)
-			case dialect.Mongo:
-				s = new(TMongoConfig)
-			}
-			if err := file.Read(".", dbconfig, s); err != nil {
-				bus.Error <- err
-				return
-			}
-			m.Lock()
-			v[c.Name] = s
-			m.Unlock()
-		}(&wg, dbconfig)
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return v, errors.New(strings.Join(unknown, "; "))
 	}
-	wg.Wait()
-	return v
+	return v, nil
 }
@@ -0,0 +1,113 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package outbox
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// ErrDuplicate Сообщение уже обработано получателем
+var ErrDuplicate = errors.New("duplicate message")
+
+// TDeduplicator Память об обработанных сообщениях получателя по ID в течение ttl
+type TDeduplicator struct {
+	m     sync.Mutex
+	ttl   time.Duration
+	seen  map[uuid.UUID]time.Time
+	order []tSeen // Сообщения в порядке отметки: устаревшие удаляются с начала
+}
+
+type tSeen struct {
+	id uuid.UUID
+	at time.Time
+}
+
+// Deduplicator Создать дедупликатор, помнящий сообщения в течение ttl
+func Deduplicator(ttl time.Duration) *TDeduplicator {
+	return &TDeduplicator{ttl: ttl, seen: make(map[uuid.UUID]time.Time)}
+}
+
+// Seen Было ли сообщение уже обработано (см. Mark)
+func (d *TDeduplicator) Seen(id uuid.UUID) bool {
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.expire(clock())
+	_, ok := d.seen[id]
+	return ok
+}
+
+// Mark Запомнить сообщение как обработанное; вызывается после успешной обработки,
+// чтобы повторная доставка сообщения, обработка которого не удалась, не терялась
+func (d *TDeduplicator) Mark(id uuid.UUID) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	now := clock()
+	d.expire(now)
+	if _, ok := d.seen[id]; ok {
+		return
+	}
+	d.seen[id] = now
+	d.order = append(d.order, tSeen{id, now})
+}
+
+// expire Забыть сообщения старше ttl
+func (d *TDeduplicator) expire(now time.Time) {
+	n := 0
+	for n < len(d.order) && now.Sub(d.order[n].at) > d.ttl {
+		delete(d.seen, d.order[n].id)
+		n++
+	}
+	d.order = d.order[n:]
+}
+
+// Received Отметить сообщение в таблице inbox в транзакции получателя;
+// true, если сообщение уже было обработано и его нужно пропустить
+func Received(tx *sql.Tx, d string, id uuid.UUID) (bool, error) {
+	q, args, err := Builder(d).Select("id").From(Inbox).Where(Eq{"id": id.String()}).ToSql()
+	if err != nil {
+		return false, err
+	}
+	var found string
+	switch err := tx.QueryRow(q, args...).Scan(&found); err {
+	case nil:
+		return true, nil
+	case sql.ErrNoRows:
+	default:
+		return false, err
+	}
+	q, args, err = Builder(d).Insert(Inbox).Columns("id", "received_at").Values(id.String(), clock().UnixNano()).ToSql()
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(q, args...); err != nil {
+		return false, err
+	}
+	return false, nil
+}
@@ -0,0 +1,153 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package outbox
+
+import (
+	"database/sql"
+	"fmt"
+
+	. "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+
+	"github.com/x-research-team/contract"
+	"github.com/x-research-team/kernel/external/system/storage/component/dialect"
+	"github.com/x-research-team/kernel/internal/tenant"
+)
+
+const (
+	// Table Таблица исходящих сообщений
+	Table = "kernel_outbox"
+	// Inbox Таблица обработанных сообщений получателя
+	Inbox = "kernel_inbox"
+)
+
+// TMessage Сообщение из outbox с сохраненным идентификатором
+type TMessage struct {
+	id      uuid.UUID
+	route   string
+	command string
+	data    string
+}
+
+// Message Сообщение outbox с новым идентификатором
+func Message(route, command, data string) *TMessage {
+	return &TMessage{uuid.New(), route, command, data}
+}
+
+// ID Correlation ID for kernel message
+func (message TMessage) ID() uuid.UUID {
+	return message.id
+}
+
+// Route Путь сообщения
+func (message TMessage) Route() string {
+	return message.route
+}
+
+// Command Команда которую нужно выполнить с данными в сообщении
+func (message TMessage) Command() string {
+	return message.command
+}
+
+// Data Данные в сообщении сигнала
+func (message TMessage) Data() string {
+	return message.data
+}
+
+// Builder Построитель запросов с плейсхолдерами диалекта
+func Builder(d string) StatementBuilderType {
+	if d == dialect.Postgres {
+		return StatementBuilder.PlaceholderFormat(Dollar)
+	}
+	return StatementBuilder.PlaceholderFormat(Question)
+}
+
+// Supported Поддерживает ли диалект outbox
+func Supported(d string) bool {
+	switch d {
+	case dialect.MySQL, dialect.SQLite, dialect.Postgres:
+		return true
+	}
+	return false
+}
+
+// Migrate Создать таблицы outbox и inbox, если их нет
+func Migrate(db *sql.DB, d string) error {
+	if !Supported(d) {
+		return fmt.Errorf("[Outbox] unsupported dialect (%v)", d)
+	}
+	text := "TEXT"
+	if d == dialect.MySQL {
+		text = "LONGTEXT"
+	}
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id VARCHAR(36) NOT NULL PRIMARY KEY,
+	tenant VARCHAR(255) NOT NULL DEFAULT '',
+	route VARCHAR(255) NOT NULL,
+	command VARCHAR(255) NOT NULL,
+	data %s NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	created_at BIGINT NOT NULL,
+	available_at BIGINT NOT NULL,
+	delivered_at BIGINT NULL,
+	last_error TEXT NULL
+)`, Table, text),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id VARCHAR(36) NOT NULL PRIMARY KEY,
+	received_at BIGINT NOT NULL
+)`, Inbox),
+	}
+	if d != dialect.MySQL {
+		statements = append(statements, fmt.Sprintf(
+			"CREATE INDEX IF NOT EXISTS %s_pending ON %s (delivered_at, available_at)", Table, Table,
+		))
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("[Outbox] %v", err)
+		}
+	}
+	return nil
+}
+
+// Enqueue Записать исходящие сообщения в outbox в рамках транзакции данных
+func Enqueue(tx *sql.Tx, d string, messages ...contract.IMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	now := clock().UnixNano()
+	insert := Builder(d).Insert(Table).Columns("id", "tenant", "route", "command", "data", "attempts", "created_at", "available_at")
+	for _, m := range messages {
+		insert = insert.Values(m.ID().String(), tenant.Of(m), m.Route(), m.Command(), m.Data(), 0, now, now)
+	}
+	query, args, err := insert.ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return fmt.Errorf("[Outbox] %v", err)
+	}
+	return nil
+}
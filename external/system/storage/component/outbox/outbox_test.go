@@ -0,0 +1,258 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package outbox
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/x-research-team/bus"
+	"github.com/x-research-team/contract"
+	"github.com/x-research-team/kernel/external/system/storage/component/dialect"
+	"github.com/x-research-team/kernel/internal/tenant"
+)
+
+// open Открыть базу SQLite во временном каталоге теста
+func open(t *testing.T, migrate bool) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(dialect.SQLite, filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if migrate {
+		if err := Migrate(db, dialect.SQLite); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// freeze Остановить часы пакета на now
+func freeze(t *testing.T, now time.Time) *time.Time {
+	t.Helper()
+	current := now
+	clock = func() time.Time { return current }
+	t.Cleanup(func() { clock = time.Now })
+	return &current
+}
+
+func enqueue(t *testing.T, db *sql.DB, messages ...contract.IMessage) {
+	t.Helper()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Enqueue(tx, dialect.SQLite, messages...); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db := open(t, true)
+	if err := Migrate(db, dialect.SQLite); err != nil {
+		t.Fatalf("second migration: %v", err)
+	}
+	for _, table := range []string{Table, Inbox} {
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+			t.Fatalf("%v: %v", table, err)
+		}
+	}
+	if err := Migrate(db, "gremlin"); err == nil {
+		t.Fatal("unsupported dialect is migrated")
+	}
+}
+
+func TestEnqueueFlush(t *testing.T) {
+	db := open(t, true)
+	first := tenant.Message("acme", Message("journal", "store", `{"n":1}`))
+	second := Message("journal", "store", `{"n":2}`)
+	enqueue(t, db, first, second)
+
+	rollback, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Enqueue(rollback, dialect.SQLite, Message("journal", "store", "lost")); err != nil {
+		t.Fatal(err)
+	}
+	rollback.Rollback()
+
+	delivered := make([]contract.IMessage, 0)
+	relay := Relay(db, dialect.SQLite)
+	n, err := relay.Flush(func(m contract.IMessage) error {
+		delivered = append(delivered, m)
+		return nil
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("Flush = %d, %v; want 2", n, err)
+	}
+	if delivered[0].ID() != first.ID() || delivered[1].ID() != second.ID() {
+		t.Fatalf("delivered %v, %v; want %v, %v", delivered[0].ID(), delivered[1].ID(), first.ID(), second.ID())
+	}
+	if tenant.Of(delivered[0]) != "acme" || delivered[0].Data() != `{"n":1}` || delivered[0].Route() != "journal" {
+		t.Fatalf("delivered %#v", delivered[0])
+	}
+	if n, err := relay.Flush(func(contract.IMessage) error { return nil }); err != nil || n != 0 {
+		t.Fatalf("second Flush = %d, %v; want 0", n, err)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	db := open(t, true)
+	now := freeze(t, time.Unix(1000, 0))
+	enqueue(t, db, Message("journal", "store", "{}"))
+	relay := Relay(db, dialect.SQLite)
+	relay.MaxAttempts = 2
+	fail := func(contract.IMessage) error { return errors.New("kernel is busy") }
+	ok := func(contract.IMessage) error { return nil }
+
+	if n, err := relay.Flush(fail); err != nil || n != 0 {
+		t.Fatalf("Flush = %d, %v", n, err)
+	}
+	var (
+		attempts  int
+		available int64
+		last      string
+	)
+	if err := db.QueryRow("SELECT attempts, available_at, last_error FROM "+Table).Scan(&attempts, &available, &last); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 1 || available != now.Add(relay.Backoff).UnixNano() || last != "kernel is busy" {
+		t.Fatalf("attempts %d, available %d, error %q", attempts, available, last)
+	}
+	if n, _ := relay.Flush(ok); n != 0 {
+		t.Fatal("message is delivered before its backoff")
+	}
+	*now = now.Add(relay.Backoff)
+	if n, _ := relay.Flush(fail); n != 0 {
+		t.Fatal("failed delivery is counted")
+	}
+	*now = now.Add(time.Hour)
+	if n, _ := relay.Flush(ok); n != 0 {
+		t.Fatal("message is delivered after MaxAttempts")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	relay := &TRelay{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	for attempts, want := range []time.Duration{time.Second, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := relay.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestRunKeepsPolling(t *testing.T) {
+	bus.Error = make(bus.TError, 8)
+	relay := Relay(open(t, false), dialect.SQLite)
+	relay.Backoff, relay.MaxBackoff = time.Millisecond, time.Millisecond
+	abort := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		relay.Run(abort, func(contract.IMessage) error { return nil })
+		close(stopped)
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-bus.Error:
+		case <-time.After(time.Second):
+			t.Fatalf("no error reported for pass %d", i)
+		}
+	}
+	close(abort)
+	for {
+		select {
+		case <-stopped:
+			return
+		case <-bus.Error:
+		case <-time.After(time.Second):
+			t.Fatal("relay does not stop on abort")
+		}
+	}
+}
+
+func TestReceived(t *testing.T) {
+	db := open(t, true)
+	id := Message("journal", "store", "{}").ID()
+	received := func(commit bool) bool {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		duplicate, err := Received(tx, dialect.SQLite, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if commit {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return duplicate
+	}
+	if received(false) {
+		t.Fatal("new message is a duplicate")
+	}
+	if received(true) {
+		t.Fatal("rolled back message is remembered")
+	}
+	if !received(true) {
+		t.Fatal("committed message is not a duplicate")
+	}
+}
+
+func TestDeduplicator(t *testing.T) {
+	now := freeze(t, time.Unix(1000, 0))
+	d := Deduplicator(time.Minute)
+	a, b := Message("r", "c", "").ID(), Message("r", "c", "").ID()
+	if d.Seen(a) {
+		t.Fatal("unmarked message is seen")
+	}
+	d.Mark(a)
+	*now = now.Add(30 * time.Second)
+	d.Mark(b)
+	if !d.Seen(a) || !d.Seen(b) {
+		t.Fatal("marked messages are not seen")
+	}
+	*now = now.Add(45 * time.Second)
+	if d.Seen(a) || !d.Seen(b) {
+		t.Fatal("expired message is seen or fresh one is not")
+	}
+	if len(d.order) != 1 || len(d.seen) != 1 {
+		t.Fatalf("%d ordered, %d seen after expiry; want 1", len(d.order), len(d.seen))
+	}
+}
@@ -0,0 +1,195 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package outbox
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	. "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+
+	"github.com/x-research-team/bus"
+	"github.com/x-research-team/contract"
+	"github.com/x-research-team/kernel/internal/tenant"
+)
+
+var clock = time.Now
+
+// TDeliver Функция доставки сообщения в ядро
+type TDeliver func(contract.IMessage) error
+
+// TRelay Ретранслятор сообщений из outbox в ядро (at-least-once)
+type TRelay struct {
+	db      *sql.DB
+	dialect string
+
+	Batch       uint64        // Сколько сообщений забирать за один проход
+	Interval    time.Duration // Период опроса таблицы
+	Backoff     time.Duration // Начальная задержка повторной доставки
+	MaxBackoff  time.Duration // Максимальная задержка повторной доставки
+	MaxAttempts int           // Предел попыток, 0 - без ограничений
+}
+
+// Relay Создать ретранслятор для соединения
+func Relay(db *sql.DB, d string) *TRelay {
+	return &TRelay{
+		db:         db,
+		dialect:    d,
+		Batch:      100,
+		Interval:   time.Second,
+		Backoff:    time.Second,
+		MaxBackoff: time.Minute,
+	}
+}
+
+// Run Доставлять сообщения до закрытия abort. Ошибка прохода отправляется в bus.Error,
+// а следующий проход откладывается с экспоненциальной задержкой от Backoff до MaxBackoff
+func (relay *TRelay) Run(abort <-chan struct{}, deliver TDeliver) {
+	failures := 0
+	for {
+		wait := relay.Interval
+		if _, err := relay.Flush(deliver); err != nil {
+			failures++
+			wait = relay.backoff(failures)
+			bus.Error <- fmt.Errorf("[Outbox] delivery failed, retry in %v: %v", wait, err)
+		} else {
+			failures = 0
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-abort:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Flush Один проход доставки: возвращает число доставленных сообщений
+func (relay *TRelay) Flush(deliver TDeliver) (int, error) {
+	pending, err := relay.pending()
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, p := range pending {
+		if err := deliver(tenant.Message(p.tenant, p.message)); err != nil {
+			if err := relay.retry(p, err); err != nil {
+				return delivered, err
+			}
+			continue
+		}
+		if err := relay.delivered(p.message.ID()); err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+	return delivered, nil
+}
+
+type pending struct {
+	message  *TMessage
+	tenant   string
+	attempts int
+}
+
+func (relay *TRelay) pending() ([]pending, error) {
+	query := Builder(relay.dialect).
+		Select("id", "tenant", "route", "command", "data", "attempts").
+		From(Table).
+		Where(Eq{"delivered_at": nil}).
+		Where(LtOrEq{"available_at": clock().UnixNano()}).
+		OrderBy("created_at").
+		Limit(relay.Batch)
+	if relay.MaxAttempts > 0 {
+		query = query.Where(Lt{"attempts": relay.MaxAttempts})
+	}
+	q, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := relay.db.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("[Outbox] %v", err)
+	}
+	defer rows.Close()
+	list := make([]pending, 0)
+	for rows.Next() {
+		var (
+			id string
+			p  pending
+		)
+		p.message = new(TMessage)
+		if err := rows.Scan(&id, &p.tenant, &p.message.route, &p.message.command, &p.message.data, &p.attempts); err != nil {
+			return nil, err
+		}
+		if p.message.id, err = uuid.Parse(id); err != nil {
+			return nil, fmt.Errorf("[Outbox] message %v: %v", id, err)
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+func (relay *TRelay) delivered(id uuid.UUID) error {
+	q, args, err := Builder(relay.dialect).
+		Update(Table).
+		Set("delivered_at", clock().UnixNano()).
+		Where(Eq{"id": id.String()}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = relay.db.Exec(q, args...)
+	return err
+}
+
+func (relay *TRelay) retry(p pending, cause error) error {
+	q, args, err := Builder(relay.dialect).
+		Update(Table).
+		Set("attempts", p.attempts+1).
+		Set("available_at", clock().Add(relay.backoff(p.attempts+1)).UnixNano()).
+		Set("last_error", cause.Error()).
+		Where(Eq{"id": p.message.ID().String()}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = relay.db.Exec(q, args...)
+	return err
+}
+
+// backoff Экспоненциальная задержка перед следующей попыткой
+func (relay *TRelay) backoff(attempts int) time.Duration {
+	d := relay.Backoff
+	for i := 1; i < attempts && d < relay.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > relay.MaxBackoff {
+		return relay.MaxBackoff
+	}
+	return d
+}
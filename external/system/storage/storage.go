@@ -24,14 +24,24 @@
 package storage
 
 import (
+	"fmt"
+
+	"github.com/x-research-team/bus"
 	"github.com/x-research-team/contract"
 	"github.com/x-research-team/kernel/external/system/storage/component"
 	"github.com/x-research-team/kernel/external/system/storage/component/dsn"
+	"github.com/x-research-team/kernel/internal/config"
+	"github.com/x-research-team/kernel/internal/tenant"
 )
 
 // Init Load plugin with all components
-func Init() contract.KernelModule {
+func Init(databases config.TDataBaseConfigs, tenants *tenant.TRegistry) contract.KernelModule {
+	connections, err := dsn.Parse(databases)
+	if err != nil {
+		bus.Error <- fmt.Errorf("[Storage] %v", err)
+	}
 	return component.New(
-		component.ConnectTo(dsn.Parse()),
+		component.ConnectTo(connections),
+		component.Tenants(tenants),
 	)
 }
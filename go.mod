@@ -18,7 +18,6 @@ require (
 	github.com/tidwall/pretty v1.1.0 // indirect
 	github.com/x-research-team/bus v0.0.0-20210307104915-782cf8ba0c40
 	github.com/x-research-team/contract v0.0.0-20210307104512-9f60da0c3706
-	github.com/x-research-team/implant v0.0.0-20210307110733-f1e9f7a9ba58
 	github.com/x-research-team/utils v0.0.0-20210315150249-a18056373ac1
 	github.com/x-research-team/vm v0.0.0-20210305090245-1710c9929861
 	go.mongodb.org/mongo-driver v1.5.1
@@ -0,0 +1,375 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/x-research-team/kernel/internal/dynamic"
+)
+
+// Prefix Префикс переменных окружения ядра
+const Prefix = "KERNEL_"
+
+// tField Поле конфигурации, переопределяемое переменной окружения
+type tField struct {
+	Key   string  // Путь поля в эффективной конфигурации
+	Env   string  // Переменная окружения
+	file  string  // Файл, из которого поле загружается
+	value *string // Строковое значение, в котором подставляются секреты
+	get   func() string
+	set   func(string) error
+}
+
+var (
+	databaseFields = []string{"DIALECT", "DATABASE", "USER", "PASSWORD", "HOST", "PORT"}
+
+	separators = regexp.MustCompile(`[^A-Za-z0-9]+`)
+	listener   = regexp.MustCompile(`^` + Prefix + `SERVER_(\d+)_`)
+)
+
+// envName Имя сегмента переменной окружения: KERNEL_DB_<NAME>_...
+func envName(s string) string {
+	return strings.ToUpper(separators.ReplaceAllString(s, "_"))
+}
+
+func list(s string) []string {
+	v := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			v = append(v, item)
+		}
+	}
+	return v
+}
+
+func str(p *string) (func() string, func(string) error) {
+	return func() string { return strconv.Quote(*p) },
+		func(s string) error { *p = s; return nil }
+}
+
+func port(p *uint) (func() string, func(string) error) {
+	return func() string { return strconv.FormatUint(uint64(*p), 10) },
+		func(s string) error {
+			v, err := strconv.ParseUint(s, 10, 16)
+			if err != nil {
+				return err
+			}
+			*p = uint(v)
+			return nil
+		}
+}
+
+func duration(p *TDuration) (func() string, func(string) error) {
+	return func() string { return time.Duration(*p).String() },
+		func(s string) error { return p.UnmarshalJSON([]byte(strconv.Quote(s))) }
+}
+
+func (c *TKernelConfig) fields() []tField {
+	fields := make([]tField, 0)
+	add := func(key, env, file string, get func() string, set func(string) error) {
+		fields = append(fields, tField{Key: key, Env: Prefix + env, file: file, get: get, set: set})
+	}
+	text := func(key, env, file string, p *string) {
+		get, set := str(p)
+		add(key, env, file, get, set)
+		fields[len(fields)-1].value = p
+	}
+
+	text("name", "NAME", "kernel.json", &c.Name)
+	text("version", "VERSION", "kernel.json", &c.Version)
+	add("validate", "VALIDATE", "kernel.json",
+		func() string { return strconv.FormatBool(c.Validate) },
+		func(s string) error {
+			v, err := strconv.ParseBool(s)
+			c.Validate = v
+			return err
+		})
+	get, set := duration(&c.Reload)
+	add("reload", "RELOAD", "kernel.json", get, set)
+	add("log.level", "LOG_LEVEL", "log.json",
+		func() string { return c.Log.Level.String() },
+		func(s string) error {
+			c.Log.Level = make(TLogLevel)
+			for _, level := range list(s) {
+				c.Log.Level[TLogLevelType(level)] = true
+			}
+			return nil
+		})
+	text("log.format", "LOG_FORMAT", "log.json", &c.Log.Format)
+	add("log.components", "LOG_COMPONENTS", "log.json",
+		func() string {
+			components := make([]string, 0, len(c.Log.Components))
+			for name, level := range c.Log.Components {
+				components = append(components, name+":"+strings.Replace(level.String(), ",", "|", -1))
+			}
+			sort.Strings(components)
+			return strings.Join(components, ",")
+		},
+		func(s string) error {
+			c.Log.Components = make(map[string]TLogLevel)
+			for _, item := range list(s) {
+				i := strings.Index(item, ":")
+				if i <= 0 {
+					return fmt.Errorf("expected Component:level|level, got %q", item)
+				}
+				level := make(TLogLevel)
+				for _, l := range strings.Split(item[i+1:], "|") {
+					if l = strings.TrimSpace(l); l != "" {
+						level[TLogLevelType(l)] = true
+					}
+				}
+				c.Log.Components[item[:i]] = level
+			}
+			return nil
+		})
+	add("log.sinks", "LOG_SINKS", "log.json",
+		func() string {
+			if len(c.Log.Sinks) == 0 {
+				return ""
+			}
+			buffer, _ := json.Marshal(c.Log.Sinks)
+			return string(buffer)
+		},
+		func(s string) error {
+			c.Log.Sinks = nil
+			return json.Unmarshal([]byte(s), &c.Log.Sinks)
+		})
+	add("log.trace", "LOG_TRACE", "log.json",
+		func() string {
+			if c.Log.Trace == nil {
+				return ""
+			}
+			buffer, _ := json.Marshal(c.Log.Trace)
+			return string(buffer)
+		},
+		func(s string) error {
+			c.Log.Trace = new(dynamic.TTraceConfig)
+			return json.Unmarshal([]byte(s), c.Log.Trace)
+		})
+	add("extensions", "EXTENSIONS", "extensions.json",
+		func() string {
+			types := make([]string, 0, len(c.Extensions))
+			for _, t := range c.Extensions {
+				types = append(types, string(t))
+			}
+			return strings.Join(types, ",")
+		},
+		func(s string) error {
+			c.Extensions = make(TComponentTypes, 0)
+			for _, t := range list(s) {
+				c.Extensions = append(c.Extensions, TComponentType(t))
+			}
+			return nil
+		})
+	add("components", "COMPONENTS", "components",
+		func() string {
+			components := make([]string, 0, len(c.Components))
+			for _, component := range c.Components.Enabled() {
+				components = append(components, component.String())
+			}
+			return strings.Join(components, ",")
+		},
+		func(s string) error {
+			c.Components = make(TComponentConfigs, 0)
+			for _, p := range list(s) {
+				c.Components = append(c.Components, TComponentConfig{
+					Path:    TComponentPath(p),
+					Enabled: true,
+				})
+			}
+			return nil
+		})
+
+	for i, l := range c.Server {
+		key, env := fmt.Sprintf("server[%d].", i), fmt.Sprintf("SERVER_%d_", i)
+		text(key+"type", env+"TYPE", "server.json", &l.Type)
+		text(key+"host", env+"HOST", "server.json", &l.Host)
+		get, set = port(&l.Port)
+		add(key+"port", env+"PORT", "server.json", get, set)
+		text(key+"prefix", env+"PREFIX", "server.json", &l.Prefix)
+		get, set = duration(&l.ReadTimeout)
+		add(key+"read_timeout", env+"READ_TIMEOUT", "server.json", get, set)
+		get, set = duration(&l.WriteTimeout)
+		add(key+"write_timeout", env+"WRITE_TIMEOUT", "server.json", get, set)
+		get, set = duration(&l.IdleTimeout)
+		add(key+"idle_timeout", env+"IDLE_TIMEOUT", "server.json", get, set)
+		get, set = duration(&l.Timeout)
+		add(key+"timeout", env+"TIMEOUT", "server.json", get, set)
+	}
+
+	names := make([]string, 0, len(c.Databases))
+	for name := range c.Databases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		db := c.Databases[name]
+		key, env := "databases."+name+".", "DB_"+envName(name)+"_"
+		for _, field := range databaseFields {
+			var p *string
+			switch field {
+			case "DIALECT":
+				p = &db.Dialect
+			case "DATABASE":
+				p = &db.Database
+			case "USER":
+				p = &db.User
+			case "PASSWORD":
+				p = &db.Password
+			case "HOST":
+				p = &db.Host
+			case "PORT":
+				get, set := port(&db.Port)
+				add(key+"port", env+field, db.file, get, set)
+				continue
+			}
+			text(key+strings.ToLower(field), env+field, db.file, p)
+		}
+	}
+	return fields
+}
+
+// environment Применить переопределения KERNEL_* из окружения (KEY=VALUE)
+func (c *TKernelConfig) environment(environ []string) TErrors {
+	var errs TErrors
+	env := make(map[string]string)
+	for _, kv := range environ {
+		if i := strings.Index(kv, "="); i > 0 && strings.HasPrefix(kv[:i], Prefix) {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	// Новые слушатели и соединения, объявленные только в окружении
+	for k := range env {
+		if m := listener.FindStringSubmatch(k); m != nil {
+			i, _ := strconv.Atoi(m[1])
+			for len(c.Server) <= i {
+				c.Server = append(c.Server, new(TListenerConfig))
+			}
+		}
+		if !strings.HasPrefix(k, Prefix+"DB_") {
+			continue
+		}
+		for _, field := range databaseFields {
+			if !strings.HasSuffix(k, "_"+field) {
+				continue
+			}
+			segment := strings.TrimSuffix(strings.TrimPrefix(k, Prefix+"DB_"), "_"+field)
+			found := false
+			for name := range c.Databases {
+				if envName(name) == segment {
+					found = true
+				}
+			}
+			if !found && segment != "" {
+				name := strings.ToLower(segment)
+				c.Databases[name] = &TDataBaseConfig{Name: name}
+			}
+			break
+		}
+	}
+
+	for _, f := range c.fields() {
+		if _, ok := c.Sources[f.Key]; !ok {
+			c.Sources[f.Key] = "default"
+		}
+		value, ok := env[f.Env]
+		if !ok {
+			continue
+		}
+		if err := f.set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", f.Env, err))
+			continue
+		}
+		c.Sources[f.Key] = "env:" + f.Env
+	}
+	return errs
+}
+
+// Print Вывести эффективную конфигурацию с источником каждого значения
+func (c *TKernelConfig) Print(w io.Writer) error {
+	if c.Profile != "" {
+		if _, err := fmt.Fprintf(w, "# profile: %s\n", c.Profile); err != nil {
+			return err
+		}
+	}
+	for _, f := range c.fields() {
+		value := c.mask(f.Key, f.get())
+		if _, err := fmt.Fprintf(w, "%s = %s\t# %s (%s)\n", f.Key, Redact(value), c.Sources[f.Key], f.Env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Environment (path: string) Загрузить переменные из .env, не перекрывая уже заданные
+func Environment(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			if value[0] == '"' {
+				if unquoted, err := strconv.Unquote(value); err == nil {
+					value = unquoted
+				}
+			} else {
+				value = value[1 : len(value)-1]
+			}
+		}
+		if _, exists := os.LookupEnv(key); exists {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,261 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setenv Задать переменную окружения на время теста
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	old, exists := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if exists {
+			os.Setenv(key, old)
+			return
+		}
+		os.Unsetenv(key)
+	})
+}
+
+// configured Конфигурация с одним слушателем и соединением journal.signal
+func configured() *TKernelConfig {
+	return &TKernelConfig{
+		Name:      "file",
+		Log:       &TLogConfig{Level: TLogLevel{"info": true}},
+		Server:    TServerConfig{{Type: "http", Port: 80}},
+		Databases: TDataBaseConfigs{"journal.signal": {Name: "journal.signal", Host: "localhost", file: "journal.signal.dbconfig"}},
+		Sources:   make(map[string]string),
+		Secrets:   make(map[string]bool),
+	}
+}
+
+func TestEnvironmentOverrides(t *testing.T) {
+	c := configured()
+	errs := c.environment([]string{
+		"KERNEL_NAME=env",
+		"KERNEL_VALIDATE=true",
+		"KERNEL_RELOAD=1500ms",
+		"KERNEL_LOG_LEVEL=error, debug",
+		"KERNEL_LOG_COMPONENTS=Storage:debug|info",
+		"KERNEL_EXTENSIONS=so,,dll",
+		"KERNEL_SERVER_0_PORT=8080",
+		"KERNEL_SERVER_1_HOST=0.0.0.0",
+		"KERNEL_DB_JOURNAL_SIGNAL_HOST=db=primary",
+		"KERNEL_DB_CACHE_PORT=6379",
+		"KERNEL_UNKNOWN=ignored",
+		"KERNEL_=ignored",
+		"NAME=ignored",
+		"malformed",
+	})
+	if len(errs) != 0 {
+		t.Fatalf("environment: %v", errs)
+	}
+	if c.Name != "env" || !c.Validate || time.Duration(c.Reload) != 1500*time.Millisecond {
+		t.Errorf("name %q, validate %v, reload %v", c.Name, c.Validate, time.Duration(c.Reload))
+	}
+	if want := (TLogLevel{"error": true, "debug": true}); !reflect.DeepEqual(c.Log.Level, want) {
+		t.Errorf("log level %v, want %v", c.Log.Level, want)
+	}
+	if want := (map[string]TLogLevel{"Storage": {"debug": true, "info": true}}); !reflect.DeepEqual(c.Log.Components, want) {
+		t.Errorf("log components %v, want %v", c.Log.Components, want)
+	}
+	if want := (TComponentTypes{"so", "dll"}); !reflect.DeepEqual(c.Extensions, want) {
+		t.Errorf("extensions %v, want %v", c.Extensions, want)
+	}
+	if len(c.Server) != 2 || c.Server[0].Port != 8080 || c.Server[0].Type != "http" || c.Server[1].Host != "0.0.0.0" {
+		t.Errorf("server %+v %+v", c.Server[0], c.Server[len(c.Server)-1])
+	}
+	if db := c.Databases["journal.signal"]; db.Host != "db=primary" {
+		t.Errorf("journal.signal host %q", db.Host)
+	}
+	if db, ok := c.Databases["cache"]; !ok || db.Port != 6379 || db.Name != "cache" {
+		t.Errorf("cache connection %+v", db)
+	}
+	if len(c.Databases) != 2 {
+		t.Errorf("connections %v", c.Databases)
+	}
+	sources := map[string]string{
+		"name":                          "env:KERNEL_NAME",
+		"server[0].port":                "env:KERNEL_SERVER_0_PORT",
+		"server[0].type":                "default",
+		"databases.journal.signal.host": "env:KERNEL_DB_JOURNAL_SIGNAL_HOST",
+		"databases.cache.port":          "env:KERNEL_DB_CACHE_PORT",
+	}
+	for key, want := range sources {
+		if got := c.Sources[key]; got != want {
+			t.Errorf("source of %v = %q, want %q", key, got, want)
+		}
+	}
+	for key, source := range c.Sources {
+		if strings.Contains(source, "UNKNOWN") {
+			t.Errorf("unknown variable is applied to %v", key)
+		}
+	}
+}
+
+func TestEnvironmentErrors(t *testing.T) {
+	c := configured()
+	errs := c.environment([]string{
+		"KERNEL_NAME=env",
+		"KERNEL_VALIDATE=maybe",
+		"KERNEL_RELOAD=soon",
+		"KERNEL_SERVER_0_PORT=70000",
+		"KERNEL_LOG_COMPONENTS=Storage",
+		"KERNEL_LOG_SINKS=[",
+	})
+	got := make([]string, 0, len(errs))
+	for _, err := range errs {
+		got = append(got, strings.SplitN(err.Error(), ":", 2)[0])
+	}
+	want := []string{"KERNEL_VALIDATE", "KERNEL_RELOAD", "KERNEL_LOG_COMPONENTS", "KERNEL_LOG_SINKS", "KERNEL_SERVER_0_PORT"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("errors %v, want variables %v", errs, want)
+	}
+	if c.Name != "env" || c.Sources["name"] != "env:KERNEL_NAME" {
+		t.Error("a bad variable stops valid overrides")
+	}
+	if c.Server[0].Port != 80 || c.Sources["server[0].port"] == "env:KERNEL_SERVER_0_PORT" {
+		t.Errorf("rejected port is applied: %v", c.Server[0].Port)
+	}
+}
+
+// tree Записать файлы конфигурации во временный каталог
+func tree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// minimal Обязательные файлы конфигурации
+func minimal(files map[string]string) map[string]string {
+	all := map[string]string{
+		"kernel.json":     `{"name": "file", "version": "1.0.0"}`,
+		"log.json":        `{"level": ["info"]}`,
+		"extensions.json": `["so"]`,
+		"components.json": `[]`,
+	}
+	for name, content := range files {
+		all[name] = content
+	}
+	return all
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := tree(t, minimal(map[string]string{
+		"server.json":                   `[{"type": "http", "host": "file", "port": 1, "prefix": "/"}]`,
+		"profiles/prod/server.json":     `[{"type": "http", "host": "profile", "port": 2, "prefix": "/"}]`,
+		"profiles/prod/kernel.json":     `{"version": "2.0.0"}`,
+		"kernel.dbconfig":               `{"name": "kernel", "dialect": "mysql", "database": "kernel", "port": 3306}`,
+		"profiles/prod/kernel.dbconfig": `{"port": 3307}`,
+	}))
+	setenv(t, "KERNEL_SERVER_0_PORT", "3")
+	setenv(t, "KERNEL_DB_KERNEL_PORT", "3308")
+	c, err := Load(dir, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kernel := filepath.Join(dir, "kernel.json") + " + " + filepath.Join(dir, "profiles", "prod", "kernel.json")
+	server := filepath.Join(dir, "server.json") + " + " + filepath.Join(dir, "profiles", "prod", "server.json")
+	cases := []struct {
+		key, source string
+		got, want   interface{}
+	}{
+		{"name", kernel, c.Name, "file"},
+		{"version", kernel, c.Version, "2.0.0"},
+		{"server[0].host", server, c.Server[0].Host, "profile"},
+		{"server[0].port", "env:KERNEL_SERVER_0_PORT", c.Server[0].Port, uint(3)},
+		{"databases.kernel.port", "env:KERNEL_DB_KERNEL_PORT", c.Databases["kernel"].Port, uint(3308)},
+	}
+	for _, e := range cases {
+		if e.got != e.want {
+			t.Errorf("%v = %v, want %v", e.key, e.got, e.want)
+		}
+		if got := c.Sources[e.key]; got != e.source {
+			t.Errorf("source of %v = %q, want %q", e.key, got, e.source)
+		}
+	}
+}
+
+func TestEnvironmentFile(t *testing.T) {
+	dir := tree(t, map[string]string{".env": strings.Join([]string{
+		"# comment",
+		"",
+		"KERNEL_TEST_PLAIN = value",
+		"export KERNEL_TEST_EXPORTED=exported",
+		`KERNEL_TEST_DOUBLE="a\tb"`,
+		`KERNEL_TEST_SINGLE='a\tb'`,
+		"KERNEL_TEST_SET=from file",
+		"KERNEL_TEST_EMPTY=",
+	}, "\n")})
+	for _, key := range []string{"KERNEL_TEST_PLAIN", "KERNEL_TEST_EXPORTED", "KERNEL_TEST_DOUBLE", "KERNEL_TEST_SINGLE", "KERNEL_TEST_EMPTY"} {
+		setenv(t, key, "")
+		os.Unsetenv(key)
+	}
+	setenv(t, "KERNEL_TEST_SET", "from environment")
+	if err := Environment(filepath.Join(dir, ".env")); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"KERNEL_TEST_PLAIN":    "value",
+		"KERNEL_TEST_EXPORTED": "exported",
+		"KERNEL_TEST_DOUBLE":   "a\tb",
+		"KERNEL_TEST_SINGLE":   `a\tb`,
+		"KERNEL_TEST_SET":      "from environment",
+		"KERNEL_TEST_EMPTY":    "",
+	}
+	for key, value := range want {
+		if got, ok := os.LookupEnv(key); !ok || got != value {
+			t.Errorf("%v = %q, %v, want %q", key, got, ok, value)
+		}
+	}
+	if err := Environment(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("missing .env: %v", err)
+	}
+	broken := tree(t, map[string]string{".env": "KERNEL_TEST_OK=1\nbroken line\n"})
+	setenv(t, "KERNEL_TEST_OK", "")
+	os.Unsetenv("KERNEL_TEST_OK")
+	if err := Environment(filepath.Join(broken, ".env")); err == nil || !strings.HasSuffix(err.Error(), ".env:2: expected KEY=VALUE") {
+		t.Errorf("Environment = %v", err)
+	}
+}
@@ -25,9 +25,16 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/x-research-team/bus"
-	"github.com/x-research-team/utils/file"
+	"github.com/x-research-team/kernel/internal/dynamic"
+	"github.com/x-research-team/kernel/internal/tenant"
 )
 
 type TLogLevelType string
@@ -43,42 +50,73 @@ func (levels *TLogLevelTypes) Has(level *TLogLevelType) bool {
 	return false
 }
 
-var LogLevels = func() *TLogLevelTypes {
-	v := new(TLogLevelTypes)
-	if err := file.Read("config", "log.json", v); err != nil {
-		panic(err)
-	}
-	return v
-}()
+type TLogLevel map[TLogLevelType]bool
 
-var ComponentConfigs = func() TComponentConfigs {
-	v := make(TComponentConfigs, 0)
-	p := make(TComponentPaths, 0)
-	if err := file.Read("config", "components.json", &p); err != nil {
-		panic(err)
+// UnmarshalJSON Уровни списком ["error", "info"] или объектом {"error": true}
+func (log *TLogLevel) UnmarshalJSON(data []byte) error {
+	levels := make(TLogLevelTypes, 0)
+	if err := json.Unmarshal(data, &levels); err == nil {
+		*log = make(TLogLevel)
+		for _, level := range levels {
+			(*log)[level] = true
+		}
+		return nil
 	}
-	types := ComponentTypes
-	for i := range p {
-		v = append(v, TComponentConfig{
-			Types:   types,
-			Path:    p[i],
-			Enabled: true,
-		})
+	v := make(map[TLogLevelType]bool)
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("levels must be a list like [\"error\", \"info\"]")
 	}
-	return v
-}()
+	*log = v
+	return nil
+}
 
-var ComponentTypes = func() TComponentTypes {
-	v := make(TComponentTypes, 0)
-	if err := file.Read("config", "extensions.json", &v); err != nil {
-		panic(err)
+// String Включенные уровни через запятую
+func (log TLogLevel) String() string {
+	levels := make([]string, 0, len(log))
+	for level, enabled := range log {
+		if enabled {
+			levels = append(levels, string(level))
+		}
 	}
-	return v
-}()
+	sort.Strings(levels)
+	return strings.Join(levels, ",")
+}
 
-type TLogLevel map[TLogLevelType]bool
+// TLogSinkConfig Приемник логов
+type TLogSinkConfig struct {
+	Type       string    `json:"type"`                 // stdout, file или journal
+	Format     string    `json:"format,omitempty"`     // json или logfmt, по умолчанию формат лога
+	Path       string    `json:"path,omitempty"`       // file: путь к файлу
+	MaxSize    int64     `json:"max_size,omitempty"`   // file: размер в байтах, после которого файл ротируется
+	MaxAge     TDuration `json:"max_age,omitempty"`    // file: время, после которого файл ротируется
+	Backups    int       `json:"backups,omitempty"`    // file: число хранимых ротированных файлов, 0 - все
+	Connection string    `json:"connection,omitempty"` // journal: соединение mongo из *.dbconfig
+	Collection string    `json:"collection,omitempty"` // journal: коллекция, по умолчанию logs
+}
+
+// TLogConfig Конфигурация логирования из log.json
 type TLogConfig struct {
-	Level TLogLevel `json:"level"`
+	Level      TLogLevel             `json:"level"`
+	Format     string                `json:"format,omitempty"`     // json или logfmt, по умолчанию logfmt
+	Components map[string]TLogLevel  `json:"components,omitempty"` // Уровни компонентов вместо общих
+	Sinks      []*TLogSinkConfig     `json:"sinks,omitempty"`      // Приемники, по умолчанию stdout
+	Trace      *dynamic.TTraceConfig `json:"trace,omitempty"`      // Трассировка динамических вызовов, по умолчанию выключена
+}
+
+// UnmarshalJSON Конфигурация объектом или, как раньше, списком уровней
+func (log *TLogConfig) UnmarshalJSON(data []byte) error {
+	levels := make(TLogLevel)
+	if err := levels.UnmarshalJSON(data); err == nil {
+		*log = TLogConfig{Level: levels}
+		return nil
+	}
+	type config TLogConfig
+	v := config{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*log = TLogConfig(v)
+	return nil
 }
 
 func (log TLogLevel) ToJson() json.RawMessage {
@@ -97,41 +135,162 @@ type TComponentPath string
 
 type TComponentPaths []TComponentPath
 
+// TComponentConfig Компоненты из kernel.json: путь или glob шаблон относительно корня плагинов
 type TComponentConfig struct {
-	Path    TComponentPath  `json:"path"`
-	Enabled bool            `json:"enabled"`
-	Types   TComponentTypes `json:"types,omitempty"`
+	Path      TComponentPath  `json:"path"`                // Каталог, файл или шаблон (*, ?, [...], ** - любое число каталогов)
+	Enabled   bool            `json:"enabled"`             // Загружать компоненты, по умолчанию true
+	Types     TComponentTypes `json:"types,omitempty"`     // Допустимые расширения файлов, по умолчанию из extensions.json
+	Recursive bool            `json:"recursive,omitempty"` // Искать плагины во всех вложенных каталогах
+	Tenant    string          `json:"tenant,omitempty"`    // Арендатор компонентов, пустой для общих
+}
+
+func (c *TComponentConfig) UnmarshalJSON(data []byte) error {
+	type component TComponentConfig
+	v := component{Enabled: true}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*c = TComponentConfig(v)
+	return nil
+}
+
+// Extensions Допустимые расширения компонента или глобальные, если они не заданы
+func (c TComponentConfig) Extensions(global TComponentTypes) TComponentTypes {
+	if len(c.Types) != 0 {
+		return c.Types
+	}
+	return global
+}
+
+func (c TComponentConfig) String() string {
+	options := make([]string, 0)
+	if len(c.Types) != 0 {
+		types := make([]string, 0, len(c.Types))
+		for _, t := range c.Types {
+			types = append(types, string(t))
+		}
+		options = append(options, "types: "+strings.Join(types, "|"))
+	}
+	if c.Recursive {
+		options = append(options, "recursive")
+	}
+	if c.Tenant != "" {
+		options = append(options, "tenant: "+c.Tenant)
+	}
+	if len(options) == 0 {
+		return string(c.Path)
+	}
+	return fmt.Sprintf("%s (%s)", c.Path, strings.Join(options, "; "))
 }
 
 type TComponentConfigs []TComponentConfig
 
+// Paths Пути включенных компонентов
 func (c TComponentConfigs) Paths() []string {
 	paths := make([]string, 0)
 	for _, config := range c {
-		paths = append(paths, string(config.Path))
+		if config.Enabled {
+			paths = append(paths, string(config.Path))
+		}
 	}
 	return paths
 }
 
+// Enabled Включенные компоненты
+func (c TComponentConfigs) Enabled() TComponentConfigs {
+	enabled := make(TComponentConfigs, 0, len(c))
+	for _, config := range c {
+		if config.Enabled {
+			enabled = append(enabled, config)
+		}
+	}
+	return enabled
+}
+
+// TDuration Длительность: строка time.ParseDuration ("5s") или число миллисекунд
+type TDuration time.Duration
+
+func (d *TDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = TDuration(v)
+		return nil
+	}
+	var ms float64
+	if err := json.Unmarshal(data, &ms); err != nil {
+		return fmt.Errorf("duration must be a string like \"5s\" or a number of milliseconds")
+	}
+	*d = TDuration(ms * float64(time.Millisecond))
+	return nil
+}
+
+func (d TDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Or Длительность или значение по умолчанию, если она не задана
+func (d TDuration) Or(v time.Duration) time.Duration {
+	if d <= 0 {
+		return v
+	}
+	return time.Duration(d)
+}
+
+// TListenerConfig Слушатель сервера из server.json
+type TListenerConfig struct {
+	Type         string    `json:"type"`   // http или ws
+	Host         string    `json:"host"`   // Адрес привязки, пустой - все интерфейсы
+	Port         uint      `json:"port"`   // Порт
+	Prefix       string    `json:"prefix"` // Префикс путей (/api, /ws)
+	ReadTimeout  TDuration `json:"read_timeout,omitempty"`
+	WriteTimeout TDuration `json:"write_timeout,omitempty"`
+	IdleTimeout  TDuration `json:"idle_timeout,omitempty"`
+	Timeout      TDuration `json:"timeout,omitempty"` // Ожидание ответа ядра для GET /api
+}
+
+// Address Адрес слушателя host:port
+func (l *TListenerConfig) Address() string {
+	return net.JoinHostPort(l.Host, strconv.FormatUint(uint64(l.Port), 10))
+}
+
+// TServerConfig Слушатели сервера
+type TServerConfig []*TListenerConfig
+
+// TDataBaseConfig Соединение из *.dbconfig
+type TDataBaseConfig struct {
+	Name     string `json:"name"`
+	Dialect  string `json:"dialect"`
+	Database string `json:"database"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	Host     string `json:"host,omitempty"`
+	Port     uint   `json:"port,omitempty"`
+
+	file string
+}
+
+// TDataBaseConfigs Соединения по имени
+type TDataBaseConfigs map[string]*TDataBaseConfig
+
+// TKernelConfig Конфигурация ядра
 type TKernelConfig struct {
+	Dir        string            `json:"-"` // Каталог, из которого загружена конфигурация
+	Profile    string            `json:"-"` // Профиль, наложенный на файлы каталога
 	Name       string            `json:"name"`
 	Version    string            `json:"version"`
 	Log        *TLogConfig       `json:"log,omitempty"`
 	Components TComponentConfigs `json:"components,omitempty"`
-}
+	Validate   bool              `json:"validate,omitempty"` // Проверять сообщения по схемам также при маршрутизации в ядре
+	Tenants    tenant.TConfigs   `json:"tenants,omitempty"`
+	Reload     TDuration         `json:"reload,omitempty"` // Период опроса каталога конфигурации
+	Extensions TComponentTypes   `json:"-"`
+	Server     TServerConfig     `json:"-"`
+	Databases  TDataBaseConfigs  `json:"-"`
 
-var Kernel = func() *TKernelConfig {
-	v := new(TKernelConfig)
-	if err := file.Read("config", "kernel.json", v); err != nil {
-		panic(err)
-	}
-	v.Log = new(TLogConfig)
-	v.Log.Level = make(TLogLevel)
-	for _, level := range *LogLevels {
-		v.Log.Level[level] = true
-	}
-	if v.Components == nil {
-		v.Components = ComponentConfigs
-	}
-	return v
-}()
+	Sources map[string]string `json:"-"` // Источник каждого значения: файл, env:<VAR> или default
+	Secrets map[string]bool   `json:"-"` // Значения, полученные из ссылок на секреты или являющиеся паролями
+}
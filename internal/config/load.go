@@ -0,0 +1,204 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/x-research-team/kernel/internal/tenant"
+)
+
+const (
+	// DefaultDir Каталог конфигурации по умолчанию
+	DefaultDir = "config"
+	// DirEnv Переменная окружения с каталогом конфигурации
+	DirEnv = "KERNEL_CONFIG_DIR"
+)
+
+// Directory Каталог конфигурации из KERNEL_CONFIG_DIR или каталог по умолчанию
+func Directory() string {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir
+	}
+	return DefaultDir
+}
+
+// TErrors Ошибки, найденные при загрузке конфигурации
+type TErrors []error
+
+func (errs TErrors) Error() string {
+	s := make([]string, 0, len(errs))
+	for _, err := range errs {
+		s = append(s, err.Error())
+	}
+	return Redact(fmt.Sprintf("[Config] %s", strings.Join(s, "; ")))
+}
+
+// Err Ошибка или nil, если ошибок нет
+func (errs TErrors) Err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Load (dir, profile: string) Загрузить конфигурацию ядра из каталога, профиля и переменных окружения.
+// Файлы профиля из <dir>/profiles/<profile> накладываются на одноименные файлы каталога (см. Merge).
+func Load(dir, profile string) (*TKernelConfig, error) {
+	var errs TErrors
+	files := make(map[string]string)
+	overlay := ""
+	if profile != "" {
+		overlay = filepath.Join(dir, ProfilesDir, profile)
+		if info, err := os.Stat(overlay); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("profile (%v) not found in %v", profile, filepath.Join(dir, ProfilesDir)))
+			overlay = ""
+		}
+	}
+	read := func(name string, v interface{}, optional bool) bool {
+		layers := make([]tLayer, 0, 2)
+		for _, d := range []string{dir, overlay} {
+			if d == "" {
+				continue
+			}
+			path := filepath.Join(d, name)
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					errs = append(errs, err)
+					return false
+				}
+				continue
+			}
+			layers = append(layers, tLayer{path: path, data: data})
+		}
+		if len(layers) == 0 {
+			if !optional {
+				errs = append(errs, fmt.Errorf("open %s: no such file or directory", filepath.Join(dir, name)))
+			}
+			return false
+		}
+		buffer, problems := check(name, layers)
+		if len(problems) > 0 {
+			errs = append(errs, problems...)
+			return false
+		}
+		if err := json.Unmarshal(buffer, v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", source(layers), err))
+			return false
+		}
+		files[name] = source(layers)
+		return true
+	}
+
+	v := &TKernelConfig{Dir: dir, Profile: profile, Sources: make(map[string]string), Secrets: make(map[string]bool)}
+	read("kernel.json", v, false)
+
+	v.Log = new(TLogConfig)
+	read("log.json", v.Log, false)
+	if v.Log.Level == nil {
+		v.Log.Level = make(TLogLevel)
+	}
+
+	v.Extensions = make(TComponentTypes, 0)
+	read("extensions.json", &v.Extensions, false)
+	if v.Components == nil {
+		paths := make(TComponentPaths, 0)
+		read("components.json", &paths, false)
+		v.Components = make(TComponentConfigs, 0, len(paths))
+		for _, p := range paths {
+			v.Components = append(v.Components, TComponentConfig{
+				Path:    p,
+				Enabled: true,
+			})
+		}
+		files["components"] = files["components.json"]
+	} else {
+		files["components"] = files["kernel.json"]
+	}
+
+	if v.Tenants == nil {
+		v.Tenants = make(tenant.TConfigs, 0)
+		read("tenants.json", &v.Tenants, true)
+	}
+
+	v.Server = make(TServerConfig, 0)
+	read("server.json", &v.Server, true)
+
+	v.Databases = make(TDataBaseConfigs)
+	names := make([]string, 0)
+	for _, d := range []string{dir, overlay} {
+		if d == "" {
+			continue
+		}
+		dbconfigs, err := filepath.Glob(filepath.Join(d, "*.dbconfig"))
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, path := range dbconfigs {
+			if name := filepath.Base(path); files[name] == "" {
+				files[name] = path
+				names = append(names, name)
+			}
+		}
+	}
+	for _, name := range names {
+		db := new(TDataBaseConfig)
+		if !read(name, db, false) {
+			continue
+		}
+		path := files[name]
+		if db.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: name of connection can not be empty", path))
+			continue
+		}
+		if other, ok := v.Databases[db.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s: connection (%v) is already defined in %v", path, db.Name, other.file))
+			continue
+		}
+		db.file = name
+		v.Databases[db.Name] = db
+	}
+
+	for _, f := range v.fields() {
+		if path, ok := files[f.file]; ok {
+			v.Sources[f.Key] = path
+		} else {
+			v.Sources[f.Key] = "default"
+		}
+	}
+	errs = append(errs, v.environment(os.Environ())...)
+	if v.Log.Trace != nil {
+		if err := v.Log.Trace.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("log: %v", err))
+		}
+	}
+	errs = append(errs, v.secrets()...)
+	return v, errs.Err()
+}
@@ -0,0 +1,115 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const (
+	// ProfileEnv Переменная окружения с именем профиля
+	ProfileEnv = "KERNEL_PROFILE"
+	// ProfilesDir Каталог профилей внутри каталога конфигурации
+	ProfilesDir = "profiles"
+
+	// Append Объект {"$append": [...]} в профиле дописывает элементы к массиву базового файла
+	Append = "$append"
+	// Replace Объект {"$replace": {...}} в профиле заменяет объект базового файла целиком
+	Replace = "$replace"
+)
+
+// Profile Профиль из KERNEL_PROFILE, пустой - без профиля
+func Profile() string {
+	return os.Getenv(ProfileEnv)
+}
+
+// Merge (base, overlay: []byte) Наложить JSON профиля на JSON базового файла.
+//
+// Объекты сливаются по ключам рекурсивно, null в профиле удаляет ключ.
+// Массивы и значения других типов заменяются значением профиля.
+// {"$append": [...]} дописывает элементы к массиву базового файла,
+// {"$replace": {...}} заменяет объект без слияния.
+func Merge(base, overlay []byte) ([]byte, error) {
+	var b, o interface{}
+	if err := json.Unmarshal(base, &b); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(overlay, &o); err != nil {
+		return nil, err
+	}
+	v, err := merge(b, o, "")
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func merge(base, overlay interface{}, pointer string) (interface{}, error) {
+	o, ok := overlay.(map[string]interface{})
+	if !ok {
+		return overlay, nil
+	}
+	if items, ok := o[Append]; ok {
+		if len(o) != 1 {
+			return nil, fmt.Errorf("%s: %s can not be combined with other keys", pointer, Append)
+		}
+		list, ok := items.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: %s expects an array", pointer, Append)
+		}
+		b, ok := base.([]interface{})
+		if !ok && base != nil {
+			return nil, fmt.Errorf("%s: %s applied to a value that is not an array", pointer, Append)
+		}
+		return append(append(make([]interface{}, 0, len(b)+len(list)), b...), list...), nil
+	}
+	if v, ok := o[Replace]; ok {
+		if len(o) != 1 {
+			return nil, fmt.Errorf("%s: %s can not be combined with other keys", pointer, Replace)
+		}
+		return v, nil
+	}
+	b, ok := base.(map[string]interface{})
+	if !ok {
+		b = make(map[string]interface{})
+	}
+	result := make(map[string]interface{}, len(b)+len(o))
+	for k, v := range b {
+		result[k] = v
+	}
+	for k, v := range o {
+		if v == nil {
+			delete(result, k)
+			continue
+		}
+		merged, err := merge(result[k], v, pointer+"/"+k)
+		if err != nil {
+			return nil, err
+		}
+		result[k] = merged
+	}
+	return result, nil
+}
@@ -0,0 +1,162 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	cases := []struct {
+		name    string
+		base    string
+		overlay string
+		want    string
+		err     string
+	}{
+		{
+			name:    "object merge",
+			base:    `{"a":1,"b":{"c":2,"d":3}}`,
+			overlay: `{"b":{"d":4,"e":5},"f":6}`,
+			want:    `{"a":1,"b":{"c":2,"d":4,"e":5},"f":6}`,
+		},
+		{
+			name:    "null deletes key",
+			base:    `{"a":1,"b":{"c":2,"d":3}}`,
+			overlay: `{"a":null,"b":{"c":null}}`,
+			want:    `{"b":{"d":3}}`,
+		},
+		{
+			name:    "null for missing key",
+			base:    `{"a":1}`,
+			overlay: `{"b":null}`,
+			want:    `{"a":1}`,
+		},
+		{
+			name:    "array replaced",
+			base:    `{"list":[1,2,3]}`,
+			overlay: `{"list":[4]}`,
+			want:    `{"list":[4]}`,
+		},
+		{
+			name:    "scalar replaces object",
+			base:    `{"a":{"b":1}}`,
+			overlay: `{"a":"flat"}`,
+			want:    `{"a":"flat"}`,
+		},
+		{
+			name:    "object replaces scalar",
+			base:    `{"a":"flat"}`,
+			overlay: `{"a":{"b":1}}`,
+			want:    `{"a":{"b":1}}`,
+		},
+		{
+			name:    "append",
+			base:    `{"list":[1,2]}`,
+			overlay: `{"list":{"$append":[3,4]}}`,
+			want:    `{"list":[1,2,3,4]}`,
+		},
+		{
+			name:    "append to missing key",
+			base:    `{}`,
+			overlay: `{"list":{"$append":[1]}}`,
+			want:    `{"list":[1]}`,
+		},
+		{
+			name:    "replace",
+			base:    `{"a":{"b":1,"c":2}}`,
+			overlay: `{"a":{"$replace":{"d":3}}}`,
+			want:    `{"a":{"d":3}}`,
+		},
+		{
+			name:    "replace with null value",
+			base:    `{"a":{"b":1}}`,
+			overlay: `{"a":{"$replace":null}}`,
+			want:    `{"a":null}`,
+		},
+		{
+			name:    "append with other keys",
+			base:    `{"list":[1]}`,
+			overlay: `{"list":{"$append":[2],"x":1}}`,
+			err:     "/list: $append can not be combined with other keys",
+		},
+		{
+			name:    "append expects array",
+			base:    `{"list":[1]}`,
+			overlay: `{"list":{"$append":2}}`,
+			err:     "/list: $append expects an array",
+		},
+		{
+			name:    "append to non-array",
+			base:    `{"a":{"list":"x"}}`,
+			overlay: `{"a":{"list":{"$append":[1]}}}`,
+			err:     "/a/list: $append applied to a value that is not an array",
+		},
+		{
+			name:    "replace with other keys",
+			base:    `{"a":{}}`,
+			overlay: `{"a":{"$replace":{},"b":1}}`,
+			err:     "/a: $replace can not be combined with other keys",
+		},
+		{
+			name:    "invalid base",
+			base:    `{`,
+			overlay: `{}`,
+			err:     "unexpected end of JSON input",
+		},
+		{
+			name:    "invalid overlay",
+			base:    `{}`,
+			overlay: `[`,
+			err:     "unexpected end of JSON input",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := Merge([]byte(c.base), []byte(c.overlay))
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("Merge error = %v, want %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var g, w interface{}
+			if err := json.Unmarshal(got, &g); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(c.want), &w); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(g, w) {
+				t.Fatalf("Merge = %s, want %s", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// KeyFile Файл ключа шифрования секретов в каталоге конфигурации
+	KeyFile = "secret.key"
+	// KeyFileEnv Переменная окружения с путем к файлу ключа
+	KeyFileEnv = "KERNEL_SECRET_KEY_FILE"
+
+	redacted = "******"
+)
+
+// reference Ссылка на секрет: ${env:NAME}, ${file:path} или ${enc:base64}
+var reference = regexp.MustCompile(`\$\{(env|file|enc):([^}]*)\}`)
+
+// secrets Значения секретов, скрываемые в логах и ошибках
+var secrets = struct {
+	sync.RWMutex
+	values map[string]bool
+}{values: make(map[string]bool)}
+
+// Secret (value: string) Скрывать значение в логах, выводе конфигурации и ошибках
+func Secret(value string) {
+	if value == "" {
+		return
+	}
+	secrets.Lock()
+	secrets.values[value] = true
+	secrets.Unlock()
+}
+
+// Redact (s: string) Заменить известные значения секретов в строке
+func Redact(s string) string {
+	secrets.RLock()
+	values := make([]string, 0, len(secrets.values))
+	for v := range secrets.values {
+		values = append(values, v)
+	}
+	secrets.RUnlock()
+	// Более длинные значения заменяются первыми, чтобы не оставить их части
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, v := range values {
+		s = strings.ReplaceAll(s, v, redacted)
+	}
+	return s
+}
+
+// TKeyring Ключ шифрования секретов, читаемый при первом использовании
+type TKeyring struct {
+	Path string
+
+	key []byte
+}
+
+// Keyring (dir: string) Ключ из KERNEL_SECRET_KEY_FILE или secret.key каталога конфигурации
+func Keyring(dir string) *TKeyring {
+	if path := os.Getenv(KeyFileEnv); path != "" {
+		return &TKeyring{Path: path}
+	}
+	return &TKeyring{Path: filepath.Join(dir, KeyFile)}
+}
+
+// Generate Создать новый ключ, если файла ключа еще нет
+func (k *TKeyring) Generate() error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(k.Path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		return err
+	}
+	k.key = key
+	return nil
+}
+
+func (k *TKeyring) cipher() (cipher.AEAD, error) {
+	if k.key == nil {
+		buffer, err := ioutil.ReadFile(k.Path)
+		if err != nil {
+			return nil, fmt.Errorf("secret key: %v", err)
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(string(buffer)))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("secret key %s: expected 64 hex characters", k.Path)
+		}
+		k.key = key
+	}
+	block, err := aes.NewCipher(k.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt (value: string) Зашифровать значение в ссылку ${enc:...}
+func (k *TKeyring) Encrypt(value string) (string, error) {
+	gcm, err := k.cipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(value), nil)
+	return "${enc:" + base64.StdEncoding.EncodeToString(sealed) + "}", nil
+}
+
+// Decrypt (value: string) Расшифровать значение ссылки ${enc:...}
+func (k *TKeyring) Decrypt(value string) (string, error) {
+	gcm, err := k.cipher()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted value is malformed")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("encrypted value can not be decrypted with the secret key")
+	}
+	return string(plain), nil
+}
+
+// resolve Подставить секреты в строку; secret - были ли в ней ссылки
+func (k *TKeyring) resolve(dir, s string) (value string, secret bool, err error) {
+	value = reference.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+		m := reference.FindStringSubmatch(ref)
+		secret = true
+		var v string
+		switch m[1] {
+		case "env":
+			var ok bool
+			if v, ok = os.LookupEnv(m[2]); !ok {
+				err = fmt.Errorf("environment variable %s is not set", m[2])
+			}
+		case "file":
+			path := m[2]
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			var buffer []byte
+			if buffer, err = ioutil.ReadFile(path); err == nil {
+				v = strings.TrimRight(string(buffer), "\r\n")
+			}
+		case "enc":
+			v, err = k.Decrypt(m[2])
+		}
+		Secret(v)
+		return v
+	})
+	return value, secret, err
+}
+
+// secrets Подставить ссылки на секреты во все строковые значения конфигурации
+func (c *TKernelConfig) secrets() TErrors {
+	var errs TErrors
+	keyring := Keyring(c.Dir)
+	for _, f := range c.fields() {
+		if f.value == nil {
+			continue
+		}
+		v, secret, err := keyring.resolve(c.Dir, *f.value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", f.Key, err))
+			continue
+		}
+		*f.value = v
+		if secret || strings.HasSuffix(f.Key, ".password") {
+			Secret(v)
+			c.Secrets[f.Key] = true
+		}
+	}
+	for i := range c.Tenants {
+		for j, token := range c.Tenants[i].Tokens {
+			v, _, err := keyring.resolve(c.Dir, token)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("tenants[%d].tokens[%d]: %v", i, j, err))
+				continue
+			}
+			Secret(v)
+			c.Tenants[i].Tokens[j] = v
+		}
+	}
+	return errs
+}
@@ -0,0 +1,75 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRedactShortSecrets(t *testing.T) {
+	Secret("pw")
+	Secret("")
+	cases := []struct {
+		text, want string
+	}{
+		{"password=pw", "password=******"},
+		{"dsn root:pw@tcp(localhost)", "dsn root:******@tcp(localhost)"},
+		{"nothing to hide", "nothing to hide"},
+	}
+	for _, c := range cases {
+		if got := Redact(c.text); got != c.want {
+			t.Errorf("Redact(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	dir := tree(t, minimal(map[string]string{
+		"kernel.dbconfig": `{"name": "kernel", "dialect": "mysql", "database": "kernel", "user": "${env:KERNEL_TEST_USER}", "password": "x1"}`,
+	}))
+	setenv(t, "KERNEL_TEST_USER", "u1")
+	c, err := Load(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := c.Databases["kernel"]
+	if db.User != "u1" || db.Password != "x1" {
+		t.Fatalf("user %q, password %q", db.User, db.Password)
+	}
+	if !c.Secrets["databases.kernel.user"] || !c.Secrets["databases.kernel.password"] || c.Secrets["databases.kernel.host"] {
+		t.Errorf("secrets %v", c.Secrets)
+	}
+	if got := Redact("login u1 with x1"); got != "login ****** with ******" {
+		t.Errorf("Redact = %q", got)
+	}
+	var b bytes.Buffer
+	if err := c.Print(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `databases.kernel.password = "******"`) || strings.Contains(b.String(), "x1") {
+		t.Errorf("Print shows the password:\n%s", b.String())
+	}
+}
@@ -0,0 +1,231 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package config
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/x-research-team/kernel/internal/schema"
+)
+
+// schemas JSON Schema файлов конфигурации: <файл>.json, dbconfig.json для *.dbconfig
+//
+//go:embed schemas/*.json
+var schemas embed.FS
+
+// tLayer Файл конфигурации: базовый или файл профиля
+type tLayer struct {
+	path string
+	data []byte
+}
+
+// source Файлы, из которых собрано значение
+func source(layers []tLayer) string {
+	paths := make([]string, 0, len(layers))
+	for _, l := range layers {
+		paths = append(paths, l.path)
+	}
+	return strings.Join(paths, " + ")
+}
+
+// Schema (name: string) Схема файла конфигурации или nil, если схемы нет
+func Schema(name string) (*schema.TSchema, error) {
+	if strings.HasSuffix(name, ".dbconfig") {
+		name = "dbconfig.json"
+	}
+	data, err := schemas.ReadFile(path.Join("schemas", name))
+	if err != nil {
+		return nil, nil
+	}
+	return schema.Parse(data)
+}
+
+// Check (name, path: string, data: []byte) Проверить JSON файла path по схеме файла name,
+// например pipeline.json для конвейеров; ошибки указывают строку и столбец значения
+func Check(name, path string, data []byte) error {
+	_, errs := check(name, []tLayer{{path: path, data: data}})
+	return errs.Err()
+}
+
+// check Проверить синтаксис слоев, наложить их и проверить результат по схеме файла.
+// Ошибки указывают файл, строку и столбец значения и его JSON pointer.
+func check(name string, layers []tLayer) ([]byte, TErrors) {
+	var errs TErrors
+	for _, l := range layers {
+		var v interface{}
+		if err := json.Unmarshal(l.data, &v); err != nil {
+			errs = append(errs, position(l, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	buffer := layers[0].data
+	for _, l := range layers[1:] {
+		merged, err := Merge(buffer, l.data)
+		if err != nil {
+			return nil, TErrors{fmt.Errorf("%s: %v", l.path, err)}
+		}
+		buffer = merged
+	}
+	s, err := Schema(name)
+	if err != nil {
+		return nil, TErrors{fmt.Errorf("schema of %s: %v", name, err)}
+	}
+	if s == nil {
+		return buffer, nil
+	}
+	var invalid schema.TErrors
+	if err := s.ValidateJSON(buffer); !errors.As(err, &invalid) {
+		return buffer, nil
+	}
+	for _, e := range invalid {
+		errs = append(errs, locate(layers, e))
+	}
+	return buffer, errs
+}
+
+// position Ошибка разбора JSON с файлом, строкой и столбцом
+func position(l tLayer, err error) error {
+	var syntax *json.SyntaxError
+	if errors.As(err, &syntax) {
+		// Offset - число прочитанных байт вместе с ошибочным символом; в конце данных символа нет
+		offset := syntax.Offset
+		if offset > 0 && offset <= int64(len(l.data)) && !strings.HasPrefix(syntax.Error(), "unexpected end") {
+			offset--
+		}
+		line, column := lineOf(l.data, offset)
+		return fmt.Errorf("%s:%d:%d: invalid JSON: %v", l.path, line, column, err)
+	}
+	return fmt.Errorf("%s: invalid JSON: %v", l.path, err)
+}
+
+// locate Найти значение ошибки в последнем слое, где оно задано, иначе ближайшего родителя
+func locate(layers []tLayer, e schema.TError) error {
+	for pointer := e.Pointer; ; pointer = parent(pointer) {
+		for i := len(layers) - 1; i >= 0; i-- {
+			if offset, ok := find(layers[i].data, pointer); ok {
+				line, column := lineOf(layers[i].data, offset)
+				return fmt.Errorf("%s:%d:%d: %v", layers[i].path, line, column, e)
+			}
+		}
+		if pointer == "" {
+			return fmt.Errorf("%s: %v", source(layers), e)
+		}
+	}
+}
+
+func parent(pointer string) string {
+	if i := strings.LastIndex(pointer, "/"); i > 0 {
+		return pointer[:i]
+	}
+	return ""
+}
+
+// lineOf Строка и столбец (с 1) смещения в данных
+func lineOf(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// find Смещение значения по JSON pointer
+func find(data []byte, pointer string) (int64, bool) {
+	target := make([]string, 0)
+	if pointer != "" {
+		for _, segment := range strings.Split(pointer[1:], "/") {
+			target = append(target, strings.NewReplacer("~1", "/", "~0", "~").Replace(segment))
+		}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	found, offset := false, int64(0)
+	// start Начало следующего значения: пропустить пробелы, запятые и двоеточия
+	start := func() int64 {
+		i := decoder.InputOffset()
+		for i < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[i]) >= 0 {
+			i++
+		}
+		return i
+	}
+	var value func(path []string) error
+	value = func(path []string) error {
+		if !found && same(path, target) {
+			found, offset = true, start()
+		}
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'):
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				if err := value(append(path[:len(path):len(path)], fmt.Sprint(key))); err != nil {
+					return err
+				}
+			}
+			_, err = decoder.Token()
+		case json.Delim('['):
+			for i := 0; decoder.More(); i++ {
+				if err := value(append(path[:len(path):len(path)], strconv.Itoa(i))); err != nil {
+					return err
+				}
+			}
+			_, err = decoder.Token()
+		}
+		return err
+	}
+	if err := value(nil); err != nil && err != io.EOF {
+		return 0, false
+	}
+	return offset, found
+}
+
+// same Совпадают ли пути по сегментам; ключи могут содержать "/"
+func same(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
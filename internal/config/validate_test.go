@@ -0,0 +1,162 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	data := []byte(`{
+  "name": "kernel",
+  "list": [1, {"a/b": [true, "x"]}],
+  "nested": {"deep": {"value": null}},
+  "a": {"b": "not a/b"}
+}`)
+	cases := []struct {
+		pointer      string
+		line, column int
+		ok           bool
+	}{
+		{"", 1, 1, true},
+		{"/name", 2, 11, true},
+		{"/list", 3, 11, true},
+		{"/list/0", 3, 12, true},
+		{"/list/1", 3, 15, true},
+		{"/list/1/a~1b/1", 3, 30, true},
+		{"/nested/deep/value", 4, 32, true},
+		{"/a/b", 5, 14, true},
+		{"/list/2", 0, 0, false},
+		{"/missing", 0, 0, false},
+		{"/name/0", 0, 0, false},
+	}
+	for _, c := range cases {
+		offset, ok := find(data, c.pointer)
+		if ok != c.ok {
+			t.Errorf("find(%q) found %v, want %v", c.pointer, ok, c.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if line, column := lineOf(data, offset); line != c.line || column != c.column {
+			t.Errorf("find(%q) at %d:%d, want %d:%d", c.pointer, line, column, c.line, c.column)
+		}
+	}
+	if _, ok := find([]byte(`{"a": [1,`), "/b"); ok {
+		t.Error("find in malformed JSON")
+	}
+}
+
+func TestLineOf(t *testing.T) {
+	data := []byte("ab\ncd\n\nef")
+	cases := []struct {
+		offset       int64
+		line, column int
+	}{
+		{0, 1, 1},
+		{1, 1, 2},
+		{3, 2, 1},
+		{6, 3, 1},
+		{8, 4, 2},
+		{100, 4, 3},
+	}
+	for _, c := range cases {
+		if line, column := lineOf(data, c.offset); line != c.line || column != c.column {
+			t.Errorf("lineOf(%d) = %d:%d, want %d:%d", c.offset, line, column, c.line, c.column)
+		}
+	}
+}
+
+func TestCheckLocation(t *testing.T) {
+	base := tLayer{path: "server.json", data: []byte(`[
+  {"type": "http", "port": 80, "prefix": "/"},
+  {"type": "ws",
+   "port": 0}
+]`)}
+	cases := []struct {
+		name   string
+		layers []tLayer
+		want   []string
+	}{
+		{"nested array value", []tLayer{base}, []string{
+			"server.json:4:12: /1/port: value must be >= 1",
+		}},
+		{"missing property at its parent", []tLayer{{path: "server.json", data: []byte(`[{"port": 1}, {"type": "http", "port": 2}]`)}}, []string{
+			"server.json:1:2: /0/type: required property is missing",
+		}},
+		{"unknown property", []tLayer{{path: "server.json", data: []byte(`[{"type": "ws", "port": 1,
+  "hots": "x"}]`)}}, []string{
+			"server.json:2:11: /0/hots: unknown property",
+		}},
+		{"value from the profile", []tLayer{
+			{path: "server.json", data: []byte(`[{"type": "http", "port": 1}]`)},
+			{path: "profiles/prod/server.json", data: []byte(`[
+  {"type": "http", "port": 1},
+  {"type": "ftp", "port": 2}
+]`)},
+		}, []string{
+			"profiles/prod/server.json:3:12: /1/type: value must be one of [http ws]",
+		}},
+		{"value from the base", []tLayer{
+			{path: "kernel.json", data: []byte(`{"name": 1}`)},
+			{path: "profiles/prod/kernel.json", data: []byte(`{"version": "2"}`)},
+		}, []string{
+			"kernel.json:1:10: /name: expected string, got integer",
+		}},
+		{"syntax error", []tLayer{{path: "server.json", data: []byte("[\n  {\"type\": }\n]")}}, []string{
+			"server.json:2:12: invalid JSON: invalid character '}' looking for beginning of value",
+		}},
+		{"unexpected end", []tLayer{{path: "server.json", data: []byte("[\n  {")}}, []string{
+			"server.json:2:4: invalid JSON: unexpected end of JSON input",
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			name := filepath.Base(c.layers[0].path)
+			_, errs := check(name, c.layers)
+			got := make([]string, 0, len(errs))
+			for _, err := range errs {
+				got = append(got, err.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(c.want, "\n") {
+				t.Errorf("errors\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(c.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestCheckPipeline(t *testing.T) {
+	err := Check("pipeline.json", "pipelines/p.json", []byte(`{"steps": [
+  {"operator": "take", "n": -1}
+]}`))
+	if err == nil || !strings.Contains(err.Error(), "pipelines/p.json:2:29: /steps/0/n: ") {
+		t.Errorf("Check = %v", err)
+	}
+	if err := Check("unknown.json", "unknown.json", []byte(`{}`)); err != nil {
+		t.Errorf("file without a schema: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TChange Изменение значения конфигурации
+type TChange struct {
+	Key string `json:"key"`
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+func (c TChange) String() string {
+	return Redact(fmt.Sprintf("%s: %s => %s", c.Key, c.Old, c.New))
+}
+
+// TChanges Изменения конфигурации
+type TChanges []TChange
+
+// Keys Ключи изменений
+func (changes TChanges) Keys() []string {
+	keys := make([]string, 0, len(changes))
+	for _, c := range changes {
+		keys = append(keys, c.Key)
+	}
+	return keys
+}
+
+// Has Есть ли изменение с ключом, начинающимся с prefix
+func (changes TChanges) Has(prefix string) bool {
+	for _, c := range changes {
+		if strings.HasPrefix(c.Key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff Различия эффективной конфигурации в порядке полей. Значения полей секретов
+// (см. TKernelConfig.Secrets) заменяются маской после сравнения
+func Diff(old, new *TKernelConfig) TChanges {
+	changes := make(TChanges, 0)
+	before := make(map[string]string)
+	for _, f := range old.fields() {
+		before[f.Key] = f.get()
+	}
+	for _, f := range new.fields() {
+		value := f.get()
+		previous, ok := before[f.Key]
+		delete(before, f.Key)
+		if ok && previous == value {
+			continue
+		}
+		if ok {
+			previous = old.mask(f.Key, previous)
+		}
+		changes = append(changes, TChange{Key: f.Key, Old: previous, New: new.mask(f.Key, value)})
+	}
+	for _, f := range old.fields() {
+		if previous, ok := before[f.Key]; ok {
+			changes = append(changes, TChange{Key: f.Key, Old: old.mask(f.Key, previous)})
+		}
+	}
+	return changes
+}
+
+// mask Скрыть значение поля key, если оно секрет
+func (c *TKernelConfig) mask(key, value string) string {
+	if c.Secrets[key] {
+		return strconv.Quote(redacted)
+	}
+	return value
+}
+
+// Watch Опрашивать каталог конфигурации и сообщать об изменениях файлов
+func Watch(dir string, interval time.Duration, abort <-chan struct{}) <-chan struct{} {
+	ch := make(chan struct{})
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		last := snapshot(dir)
+		for {
+			select {
+			case <-abort:
+				return
+			case <-ticker.C:
+			}
+			current := snapshot(dir)
+			if current == last {
+				continue
+			}
+			last = current
+			select {
+			case ch <- struct{}{}:
+			case <-abort:
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// snapshot Отпечаток каталога: пути, размеры и время изменения файлов
+func snapshot(dir string) string {
+	var b strings.Builder
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return b.String()
+}
@@ -0,0 +1,78 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := configured()
+	old.Databases["kernel"] = &TDataBaseConfig{Name: "kernel", Host: "a", Password: "old-password"}
+	old.Secrets["databases.kernel.password"] = true
+	old.Secrets["databases.journal.signal.password"] = true
+	old.Databases["journal.signal"].Password = "gone"
+	new := configured()
+	new.Name = "renamed"
+	delete(new.Databases, "journal.signal")
+	new.Databases["kernel"] = &TDataBaseConfig{Name: "kernel", Host: "b", Password: "new-password"}
+	new.Secrets["databases.kernel.password"] = true
+
+	changes := Diff(old, new)
+	want := TChanges{
+		{Key: "name", Old: `"file"`, New: `"renamed"`},
+		{Key: "databases.kernel.password", Old: `"******"`, New: `"******"`},
+		{Key: "databases.kernel.host", Old: `"a"`, New: `"b"`},
+	}
+	got := make(TChanges, 0)
+	removed := make(TChanges, 0)
+	for _, c := range changes {
+		if strings.HasPrefix(c.Key, "databases.journal.signal.") {
+			removed = append(removed, c)
+			continue
+		}
+		got = append(got, c)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes %v, want %v", got, want)
+	}
+	for _, c := range removed {
+		if c.New != "" {
+			t.Errorf("removed %v has a new value", c)
+		}
+		if c.Key == "databases.journal.signal.password" && c.Old != `"******"` {
+			t.Errorf("removed password is shown: %v", c)
+		}
+	}
+	for _, c := range changes {
+		if s := c.String(); strings.Contains(s, "old-password") || strings.Contains(s, "new-password") || strings.Contains(s, "gone") {
+			t.Errorf("change shows a secret: %v", s)
+		}
+	}
+	if len(Diff(old, old)) != 0 {
+		t.Error("unchanged configuration has changes")
+	}
+}
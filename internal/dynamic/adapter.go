@@ -0,0 +1,106 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package dynamic
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// names Имена функций по адресу: uintptr -> string
+var names sync.Map
+
+// arguments Проверить число аргументов функции типа t и привести их к типам параметров
+func arguments(f interface{}, t reflect.Type, args []interface{}) ([]reflect.Value, error) {
+	n := t.NumIn()
+	variadic := t.IsVariadic()
+	if variadic {
+		if len(args) < n-1 {
+			return nil, &TCallError{Func: funcName(f), Arg: -1, Reason: fmt.Sprintf("expected at least %d, got %d", n-1, len(args)), Err: ErrArity}
+		}
+	} else if len(args) != n {
+		return nil, &TCallError{Func: funcName(f), Arg: -1, Reason: fmt.Sprintf("expected %d, got %d", n, len(args)), Err: ErrArity}
+	}
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var p reflect.Type
+		if variadic && i >= n-1 {
+			p = t.In(n - 1).Elem()
+		} else {
+			p = t.In(i)
+		}
+		v, err := convert(arg, p)
+		if err != nil {
+			return nil, &TCallError{Func: funcName(f), Arg: i, Reason: err.Error(), Err: ErrArgument}
+		}
+		in[i] = v
+	}
+	return in, nil
+}
+
+// fast Вызвать без отражения функции распространенных сигнатур.
+// false, если сигнатура не подходит или число аргументов неверно — тогда ошибку формирует общий путь
+func fast(f interface{}, args []interface{}) ([]interface{}, bool) {
+	switch fn := f.(type) {
+	case func(interface{}) interface{}:
+		if fn == nil || len(args) != 1 {
+			return nil, false
+		}
+		return []interface{}{fn(args[0])}, true
+	case func(interface{}) bool:
+		if fn == nil || len(args) != 1 {
+			return nil, false
+		}
+		return []interface{}{fn(args[0])}, true
+	case func(...interface{}) []interface{}:
+		if fn == nil {
+			return nil, false
+		}
+		return []interface{}{fn(args...)}, true
+	}
+	return nil, false
+}
+
+// funcName Имя функции; runtime.FuncForPC вызывается один раз на функцию
+func funcName(i interface{}) string {
+	v := reflect.ValueOf(i)
+	if i == nil || v.Kind() != reflect.Func {
+		return fmt.Sprintf("%T", i)
+	}
+	pc := v.Pointer()
+	if pc == 0 {
+		return v.Type().String()
+	}
+	if name, ok := names.Load(pc); ok {
+		return name.(string)
+	}
+	name := v.Type().String()
+	if f := runtime.FuncForPC(pc); f != nil {
+		name = f.Name()
+	}
+	names.Store(pc, name)
+	return name
+}
@@ -0,0 +1,164 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package dynamic
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"runtime/debug"
+	"time"
+)
+
+var (
+	// ErrNotFunction Вызываемое значение не является функцией
+	ErrNotFunction = errors.New("not a function")
+	// ErrArity Неверное число аргументов
+	ErrArity = errors.New("wrong number of arguments")
+	// ErrArgument Аргумент нельзя передать в параметр
+	ErrArgument = errors.New("wrong argument type")
+	// ErrPanic Паника внутри вызванной функции
+	ErrPanic = errors.New("panic")
+)
+
+// TCallError Ошибка динамического вызова
+type TCallError struct {
+	Func   string // Имя функции
+	Arg    int    // Индекс аргумента или -1
+	Reason string
+	Stack  []byte // Стек паники
+	Err    error  // ErrNotFunction, ErrArity, ErrArgument или ErrPanic
+}
+
+func (e *TCallError) Error() string {
+	if e.Arg >= 0 {
+		return fmt.Sprintf("[Command] %v: %v: argument %d: %v", e.Func, e.Err, e.Arg, e.Reason)
+	}
+	return fmt.Sprintf("[Command] %v: %v: %v", e.Func, e.Err, e.Reason)
+}
+
+func (e *TCallError) Unwrap() error {
+	return e.Err
+}
+
+// CallE Вызвать функцию с проверкой числа и типов аргументов.
+// Аргументы приводятся к типам параметров, если это возможно без потери значения,
+// nil передается как нулевое значение ссылочных типов. Паника вызванной функции
+// возвращается как ErrPanic со стеком.
+func CallE(f interface{}, args ...interface{}) ([]interface{}, error) {
+	if !traced() {
+		return invoke(f, args)
+	}
+	start := time.Now()
+	result, err := invoke(f, args)
+	record(f, args, result, err, start)
+	return result, err
+}
+
+// invoke Вызвать функцию без трассировки (см. CallE)
+func invoke(f interface{}, args []interface{}) (result []interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, &TCallError{Func: funcName(f), Arg: -1, Reason: fmt.Sprint(r), Stack: debug.Stack(), Err: ErrPanic}
+		}
+	}()
+	result, ok := fast(f, args)
+	if !ok {
+		fn := reflect.ValueOf(f)
+		if f == nil || fn.Kind() != reflect.Func {
+			return nil, &TCallError{Func: fmt.Sprintf("%T", f), Arg: -1, Reason: fmt.Sprintf("%T is not callable", f), Err: ErrNotFunction}
+		}
+		if fn.IsNil() {
+			return nil, &TCallError{Func: funcName(f), Arg: -1, Reason: "function is nil", Err: ErrNotFunction}
+		}
+		in, err := arguments(f, fn.Type(), args)
+		if err != nil {
+			return nil, err
+		}
+		out := fn.Call(in)
+		result = make([]interface{}, len(out))
+		for i, value := range out {
+			result[i] = value.Interface()
+		}
+	}
+	return result, nil
+}
+
+// convert Привести значение к типу t: присваиванием или преобразованием без потери значения
+func convert(arg interface{}, t reflect.Type) (reflect.Value, error) {
+	if arg == nil {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return reflect.Zero(t), nil
+		}
+		return reflect.Value{}, fmt.Errorf("nil is not assignable to %v", t)
+	}
+	v := reflect.ValueOf(arg)
+	if v.Type().AssignableTo(t) {
+		return v, nil
+	}
+	if !v.Type().ConvertibleTo(t) || !lossless(v, t) {
+		return reflect.Value{}, fmt.Errorf("%v is not assignable to %v", v.Type(), t)
+	}
+	return v.Convert(t), nil
+}
+
+// lossless Сохраняет ли преобразование значение: числа остаются теми же числами,
+// а целые не превращаются в символы строк
+func lossless(v reflect.Value, t reflect.Type) bool {
+	switch {
+	case t.Kind() == reflect.String:
+		return v.Kind() == reflect.String || v.Kind() == reflect.Slice
+	case numeric(v.Kind()) && numeric(t.Kind()):
+		c := v.Convert(t)
+		back := c.Convert(v.Type())
+		if f := float(v); f != float(back) || (isFloat(v.Kind()) && math.IsNaN(f)) {
+			return false
+		}
+		return (float(v) < 0) == (float(c) < 0)
+	case numeric(v.Kind()) || numeric(t.Kind()):
+		return false
+	}
+	return true
+}
+
+func numeric(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
+}
+
+func isFloat(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
+func float(v reflect.Value) float64 {
+	switch {
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		return float64(v.Int())
+	case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uintptr:
+		return float64(v.Uint())
+	default:
+		return v.Float()
+	}
+}
@@ -0,0 +1,288 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package dynamic
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/x-research-team/bus"
+)
+
+// failure Ошибка вызова или проверка, что ее нет: cause - ожидаемая причина, reason - часть текста
+func failure(t *testing.T, err error, cause error, arg int, reason string) {
+	t.Helper()
+	if cause == nil {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return
+	}
+	var e *TCallError
+	if !errors.As(err, &e) || !errors.Is(err, cause) {
+		t.Errorf("error %v, want %v", err, cause)
+		return
+	}
+	if e.Arg != arg || !strings.Contains(e.Reason, reason) {
+		t.Errorf("error at argument %d: %q, want %d: %q", e.Arg, e.Reason, arg, reason)
+	}
+}
+
+func TestCallArity(t *testing.T) {
+	sum := func(a, b int) int { return a + b }
+	join := func(sep string, parts ...string) string { return strings.Join(parts, sep) }
+	cases := []struct {
+		name   string
+		f      interface{}
+		args   []interface{}
+		want   interface{}
+		err    error
+		reason string
+	}{
+		{"exact", sum, List(1, 2), 3, nil, ""},
+		{"too few", sum, List(1), nil, ErrArity, "expected 2, got 1"},
+		{"too many", sum, List(1, 2, 3), nil, ErrArity, "expected 2, got 3"},
+		{"variadic empty", join, List("-"), "", nil, ""},
+		{"variadic", join, List("-", "a", "b", "c"), "a-b-c", nil, ""},
+		{"variadic too few", join, List(), nil, ErrArity, "expected at least 1, got 0"},
+		{"variadic element type", join, List("-", "a", 1), nil, ErrArgument, "int is not assignable to string"},
+		{"no parameters", func() int { return 7 }, List(), 7, nil, ""},
+		{"no parameters with arguments", func() int { return 7 }, List(1), nil, ErrArity, "expected 0, got 1"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := CallE(c.f, c.args...)
+			arg := -1
+			if c.err == ErrArgument {
+				arg = len(c.args) - 1
+			}
+			failure(t, err, c.err, arg, c.reason)
+			if c.err == nil && (len(result) != 1 || result[0] != c.want) {
+				t.Errorf("result %v, want %v", result, c.want)
+			}
+		})
+	}
+}
+
+func TestCallConvert(t *testing.T) {
+	cases := []struct {
+		name   string
+		f      interface{}
+		arg    interface{}
+		want   interface{}
+		reason string
+	}{
+		{"int to float64", func(x float64) float64 { return x }, 2, 2.0, ""},
+		{"whole float64 to int", func(x int) int { return x }, 3.0, 3, ""},
+		{"float64 to int8", func(x int8) int8 { return x }, -128.0, int8(-128), ""},
+		{"uint8 to int", func(x int) int { return x }, uint8(255), 255, ""},
+		{"fraction to int", func(x int) int { return x }, 3.5, nil, "float64 is not assignable to int"},
+		{"overflow", func(x uint8) uint8 { return x }, 300, nil, "int is not assignable to uint8"},
+		{"negative to uint", func(x uint) uint { return x }, -1, nil, "int is not assignable to uint"},
+		{"large int to float32", func(x float32) float32 { return x }, 1<<40 + 1, nil, "int is not assignable to float32"},
+		{"NaN to float32", func(x float32) float32 { return x }, math.NaN(), nil, "float64 is not assignable to float32"},
+		{"int to string", func(s string) string { return s }, 65, nil, "int is not assignable to string"},
+		{"bytes to string", func(s string) string { return s }, []byte("ab"), "ab", ""},
+		{"named string", func(s string) string { return s }, tName("x"), "x", ""},
+		{"bool to int", func(x int) int { return x }, true, nil, "bool is not assignable to int"},
+		{"value to interface", func(v interface{}) interface{} { return v }, 1, 1, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := CallE(c.f, c.arg)
+			if c.reason != "" {
+				failure(t, err, ErrArgument, 0, c.reason)
+				return
+			}
+			failure(t, err, nil, 0, "")
+			if len(result) != 1 || result[0] != c.want {
+				t.Errorf("result %#v, want %#v", result, c.want)
+			}
+		})
+	}
+}
+
+// tName Именованный строковый тип
+type tName string
+
+func TestCallNil(t *testing.T) {
+	cases := []struct {
+		name string
+		f    interface{}
+	}{
+		{"pointer", func(p *int) bool { return p == nil }},
+		{"interface", func(v error) bool { return v == nil }},
+		{"func", func(f func()) bool { return f == nil }},
+		{"map", func(m map[string]int) bool { return m == nil }},
+		{"slice", func(s []int) bool { return s == nil }},
+		{"chan", func(c chan int) bool { return c == nil }},
+	}
+	for _, c := range cases {
+		result, err := CallE(c.f, nil)
+		if err != nil || len(result) != 1 || result[0] != true {
+			t.Errorf("%v: %v, %v", c.name, result, err)
+		}
+	}
+	_, err := CallE(func(x int) int { return x }, nil)
+	failure(t, err, ErrArgument, 0, "nil is not assignable to int")
+}
+
+func TestCallNotFunction(t *testing.T) {
+	var nilFunc func()
+	cases := []struct {
+		name   string
+		f      interface{}
+		reason string
+	}{
+		{"value", 42, "int is not callable"},
+		{"nil", nil, "<nil> is not callable"},
+		{"nil func", nilFunc, "function is nil"},
+	}
+	for _, c := range cases {
+		_, err := CallE(c.f)
+		failure(t, err, ErrNotFunction, -1, c.reason)
+	}
+}
+
+func TestCallPanic(t *testing.T) {
+	result, err := CallE(func(s []int) int { return s[3] }, []int{1})
+	failure(t, err, ErrPanic, -1, "index out of range")
+	var e *TCallError
+	if !errors.As(err, &e) || !strings.Contains(string(e.Stack), "runtime/debug.Stack") || result != nil {
+		t.Errorf("panic without a stack: %v, %v", result, err)
+	}
+	if !strings.Contains(err.Error(), "panic: runtime error: index out of range") {
+		t.Errorf("message %q", err)
+	}
+}
+
+func TestCallErrorResult(t *testing.T) {
+	errBroken := errors.New("broken")
+	f := func(x int) (int, error) {
+		if x < 0 {
+			return 0, errBroken
+		}
+		return x, nil
+	}
+	result, err := CallE(f, -1)
+	if err != nil || len(result) != 2 || result[1] != errBroken {
+		t.Errorf("result %v, %v: an error result is returned as a value", result, err)
+	}
+	result, err = CallE(f, 1)
+	if err != nil || len(result) != 2 || result[0] != 1 || result[1] != nil {
+		t.Errorf("result %v, %v", result, err)
+	}
+
+	errs := make(bus.TError, 1)
+	old := bus.Error
+	bus.Error = errs
+	defer func() { bus.Error = old }()
+	if result := Call(f, "x"); result != nil {
+		t.Errorf("Call result %v", result)
+	}
+	select {
+	case err := <-errs:
+		if !errors.Is(err.(error), ErrArgument) {
+			t.Errorf("bus.Error got %v", err)
+		}
+	default:
+		t.Error("Call does not report the error to bus.Error")
+	}
+}
+
+// previous Вызов, как его делал Call до CallE: reflect.Value.Call без проверок, кеша и быстрых путей
+func previous(f interface{}, args ...interface{}) []interface{} {
+	var result []interface{}
+	fn := reflect.ValueOf(f)
+	in := make([]reflect.Value, len(args))
+	for i := range args {
+		if args[i] == nil {
+			in[i] = reflect.New(reflect.TypeOf(f).In(i))
+			continue
+		}
+		in[i] = reflect.ValueOf(args[i])
+	}
+	out := fn.Call(in)
+	for _, value := range out {
+		result = append(result, value.Interface())
+	}
+	return result
+}
+
+// results Результат последнего вызова бенчмарка, чтобы вызов не был выброшен компилятором
+var results []interface{}
+
+func benchmarkCall(b *testing.B, f interface{}, want interface{}, args ...interface{}) {
+	if r := previous(f, args...); !reflect.DeepEqual(r[0], want) {
+		b.Fatalf("previous = %v, want %v", r[0], want)
+	}
+	if r, err := CallE(f, args...); err != nil || !reflect.DeepEqual(r[0], want) {
+		b.Fatalf("CallE = %v, %v; want %v", r, err, want)
+	}
+	b.Run("previous", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			results = previous(f, args...)
+		}
+	})
+	b.Run("CallE", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			results, _ = CallE(f, args...)
+		}
+	})
+}
+
+// BenchmarkCallFast Сигнатуры, вызываемые без отражения
+func BenchmarkCallFast(b *testing.B) {
+	b.Run("map", func(b *testing.B) {
+		benchmarkCall(b, func(e interface{}) interface{} { return e.(int) * 2 }, 4, 2)
+	})
+	b.Run("filter", func(b *testing.B) {
+		benchmarkCall(b, func(e interface{}) bool { return e.(int) > 1 }, true, 2)
+	})
+	b.Run("list", func(b *testing.B) {
+		benchmarkCall(b, func(xs ...interface{}) []interface{} { return xs[:1] }, List(1), 1, 2)
+	})
+}
+
+// BenchmarkCallChecked Типизированные сигнатуры общим путем: проверка и приведение аргументов
+// добавляют к прежнему вызову около десятой доли
+func BenchmarkCallChecked(b *testing.B) {
+	b.Run("typed", func(b *testing.B) {
+		benchmarkCall(b, func(a, b int) int { return a + b }, 3, 1, 2)
+	})
+	b.Run("variadic", func(b *testing.B) {
+		benchmarkCall(b, func(xs ...float64) float64 {
+			s := 0.0
+			for _, x := range xs {
+				s += x
+			}
+			return s
+		}, 6.0, 1.0, 2.0, 3.0)
+	})
+}
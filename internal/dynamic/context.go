@@ -0,0 +1,98 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package dynamic
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// accepts Принимает ли параметр t контекст: context.Context или интерфейс, который он реализует.
+// Пустой интерфейс не считается, иначе лямбды func(interface{}) получали бы контекст вместо элемента
+func accepts(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface && t.NumMethod() > 0 && contextType.Implements(t)
+}
+
+// Contextual (f: Lambda) Принимает ли функция контекст первым параметром
+func Contextual(f Lambda) bool {
+	t := reflect.TypeOf(f)
+	return f != nil && t.Kind() == reflect.Func && contextual(t)
+}
+
+// contextual Принимает ли функция типа t контекст первым параметром
+func contextual(t reflect.Type) bool {
+	return t.NumIn() > 0 && accepts(t.In(0))
+}
+
+// CallContext Вызвать функцию с контекстом (см. CallE). Функции, первый параметр которых
+// принимает context.Context, получают ctx перед аргументами и должны сами прерываться по нему.
+// Вызов выполняется в вызывающей горутине: функция, не принимающая контекст, выполняется до конца.
+// Если контекст отменен или его срок истек к возврату функции, возвращается TCallError
+// с ctx.Err() (context.DeadlineExceeded или context.Canceled), а результат функции отбрасывается
+func CallContext(ctx context.Context, f interface{}, args ...interface{}) ([]interface{}, error) {
+	if !traced() {
+		return invokeContext(ctx, f, args)
+	}
+	start := time.Now()
+	result, err := invokeContext(ctx, f, args)
+	record(f, args, result, err, start)
+	return result, err
+}
+
+// invokeContext Вызвать функцию с контекстом без трассировки (см. CallContext)
+func invokeContext(ctx context.Context, f interface{}, args []interface{}) ([]interface{}, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, &TCallError{Func: funcName(f), Arg: -1, Reason: "not called", Err: err}
+	}
+	if Contextual(f) {
+		args = append([]interface{}{ctx}, args...)
+	}
+	result, err := invoke(f, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, &TCallError{Func: funcName(f), Arg: -1, Reason: "result discarded", Err: err}
+	}
+	return result, nil
+}
+
+// CallTimeout Вызвать функцию со сроком timeout (см. CallContext)
+func CallTimeout(timeout time.Duration, f interface{}, args ...interface{}) ([]interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return CallContext(ctx, f, args...)
+}
+
+// Cancelled (err: error) Является ли ошибка отменой вызова или истечением его срока
+func Cancelled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,102 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package dynamic
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// wait Функция, ожидающая отмены контекста или истечения d
+func wait(ctx context.Context, d time.Duration) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-time.After(d):
+		return "done", nil
+	}
+}
+
+func TestCallContextReturn(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result, err := CallContext(ctx, wait, time.Millisecond)
+	if err != nil || len(result) != 2 || result[0] != "done" {
+		t.Errorf("result %v, %v", result, err)
+	}
+	result, err = CallContext(nil, func(a, b int) int { return a + b }, 1, 2)
+	if err != nil || result[0] != 3 {
+		t.Errorf("nil context: %v, %v", result, err)
+	}
+	if !Contextual(wait) || Contextual(func(v interface{}) {}) || Contextual(42) || Contextual(nil) {
+		t.Error("Contextual")
+	}
+}
+
+func TestCallContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond, cancel)
+	start := time.Now()
+	result, err := CallContext(ctx, wait, time.Minute)
+	if !errors.Is(err, context.Canceled) || result != nil || !Cancelled(err) {
+		t.Errorf("result %v, %v", result, err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("the function does not receive the context")
+	}
+	called := false
+	_, err = CallContext(ctx, func() { called = true })
+	if !errors.Is(err, context.Canceled) || called {
+		t.Errorf("cancelled context: called %v, %v", called, err)
+	}
+}
+
+func TestCallContextDeadline(t *testing.T) {
+	result, err := CallTimeout(time.Millisecond, wait, time.Minute)
+	if !errors.Is(err, context.DeadlineExceeded) || result != nil {
+		t.Errorf("result %v, %v", result, err)
+	}
+
+	// Функция без контекста выполняется до конца в вызывающей горутине, результат отбрасывается
+	goroutines := runtime.NumGoroutine()
+	finished := false
+	_, err = CallTimeout(time.Millisecond, func() int {
+		if n := runtime.NumGoroutine(); n > goroutines {
+			t.Errorf("call runs in a new goroutine: %d > %d", n, goroutines)
+		}
+		time.Sleep(5 * time.Millisecond)
+		finished = true
+		return 1
+	})
+	var e *TCallError
+	if !errors.As(err, &e) || !errors.Is(err, context.DeadlineExceeded) || e.Reason != "result discarded" || !finished {
+		t.Errorf("finished %v, %v", finished, err)
+	}
+	if Cancelled(errors.New("other")) {
+		t.Error("Cancelled(other)")
+	}
+}
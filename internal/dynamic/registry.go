@@ -0,0 +1,264 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package dynamic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// ErrNotRegistered Функция не зарегистрирована
+var ErrNotRegistered = errors.New("function is not registered")
+
+// TParam Параметр зарегистрированной функции
+type TParam struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Variadic bool   `json:"variadic,omitempty"`
+
+	t reflect.Type
+}
+
+// TFunction Зарегистрированная функция
+type TFunction struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Params      []TParam `json:"params"`
+	Results     []string `json:"results"`
+
+	f interface{}
+}
+
+// TRegistry Реестр функций, вызываемых по имени
+type TRegistry struct {
+	m         sync.RWMutex
+	functions map[string]*TFunction
+}
+
+// Functions Общий реестр функций ядра и компонентов
+var Functions = Registry()
+
+// Registry Создать пустой реестр функций
+func Registry() *TRegistry {
+	return &TRegistry{functions: make(map[string]*TFunction)}
+}
+
+// Register (name, description: string, f: Lambda, params: ...string) Зарегистрировать функцию
+// с именами параметров по порядку; имена, которых не хватает, получают вид argN.
+// Первый параметр context.Context не входит в параметры: его передает CallContext
+func (r *TRegistry) Register(name, description string, f Lambda, params ...string) error {
+	t := reflect.TypeOf(f)
+	if f == nil || t.Kind() != reflect.Func || reflect.ValueOf(f).IsNil() {
+		return &TCallError{Func: name, Arg: -1, Reason: fmt.Sprintf("%T is not callable", f), Err: ErrNotFunction}
+	}
+	offset := 0
+	if contextual(t) {
+		offset = 1
+	}
+	if len(params) > t.NumIn()-offset {
+		return fmt.Errorf("[Command] %v: %d parameter names for %d parameters", name, len(params), t.NumIn()-offset)
+	}
+	fn := &TFunction{Name: name, Description: description, Params: make([]TParam, 0, t.NumIn()), Results: make([]string, 0, t.NumOut()), f: f}
+	for i := offset; i < t.NumIn(); i++ {
+		p := TParam{Name: fmt.Sprintf("arg%d", i-offset), t: t.In(i)}
+		if i-offset < len(params) {
+			p.Name = params[i-offset]
+		}
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			p.Variadic = true
+			p.Type = "..." + t.In(i).Elem().String()
+		} else {
+			p.Type = t.In(i).String()
+		}
+		fn.Params = append(fn.Params, p)
+	}
+	for i := 0; i < t.NumOut(); i++ {
+		fn.Results = append(fn.Results, t.Out(i).String())
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.functions[name] = fn
+	return nil
+}
+
+// Unregister (name: string) Удалить функцию из реестра
+func (r *TRegistry) Unregister(name string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	delete(r.functions, name)
+}
+
+// Lookup (name: string) Найти функцию
+func (r *TRegistry) Lookup(name string) (*TFunction, bool) {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	fn, ok := r.functions[name]
+	return fn, ok
+}
+
+// List Функции реестра по имени
+func (r *TRegistry) List() []*TFunction {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	list := make([]*TFunction, 0, len(r.functions))
+	for _, fn := range r.functions {
+		list = append(list, fn)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Call (name: string, args: ...any) Вызвать функцию по имени (см. CallE)
+func (r *TRegistry) Call(name string, args ...interface{}) ([]interface{}, error) {
+	return r.CallContext(context.Background(), name, args...)
+}
+
+// CallContext (ctx: context.Context, name: string, args: ...any) Вызвать функцию по имени с контекстом (см. dynamic.CallContext)
+func (r *TRegistry) CallContext(ctx context.Context, name string, args ...interface{}) ([]interface{}, error) {
+	fn, ok := r.Lookup(name)
+	if !ok {
+		return nil, &TCallError{Func: name, Arg: -1, Reason: "unknown function", Err: ErrNotRegistered}
+	}
+	return CallContext(ctx, fn.f, args...)
+}
+
+// CallJSON (name: string, args: json.RawMessage) Вызвать функцию с аргументами JSON:
+// массивом по порядку параметров или объектом по их именам. Результат - JSON значение
+// единственного результата, массив нескольких или null; последний результат типа error
+// возвращается как ошибка
+func (r *TRegistry) CallJSON(name string, args json.RawMessage) (json.RawMessage, error) {
+	return r.CallJSONContext(context.Background(), name, args)
+}
+
+// CallJSONContext (ctx: context.Context, name: string, args: json.RawMessage) Вызвать функцию
+// с аргументами JSON и контекстом (см. CallJSON, CallContext)
+func (r *TRegistry) CallJSONContext(ctx context.Context, name string, args json.RawMessage) (json.RawMessage, error) {
+	fn, ok := r.Lookup(name)
+	if !ok {
+		return nil, &TCallError{Func: name, Arg: -1, Reason: "unknown function", Err: ErrNotRegistered}
+	}
+	values, err := fn.decode(args)
+	if err != nil {
+		return nil, err
+	}
+	results, err := CallContext(ctx, fn.f, values...)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(results); n > 0 && fn.Results[n-1] == "error" {
+		if err, _ := results[n-1].(error); err != nil {
+			return nil, err
+		}
+		results = results[:n-1]
+	}
+	switch len(results) {
+	case 0:
+		return json.RawMessage("null"), nil
+	case 1:
+		return json.Marshal(results[0])
+	default:
+		return json.Marshal(results)
+	}
+}
+
+// decode Разобрать аргументы JSON в значения типов параметров
+func (fn *TFunction) decode(args json.RawMessage) ([]interface{}, error) {
+	raw := make([]json.RawMessage, 0, len(fn.Params))
+	args = bytes.TrimSpace(args)
+	switch {
+	case len(args) == 0 || bytes.Equal(args, []byte("null")):
+	case args[0] == '[':
+		if err := json.Unmarshal(args, &raw); err != nil {
+			return nil, &TCallError{Func: fn.Name, Arg: -1, Reason: err.Error(), Err: ErrArgument}
+		}
+	case args[0] == '{':
+		named := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(args, &named); err != nil {
+			return nil, &TCallError{Func: fn.Name, Arg: -1, Reason: err.Error(), Err: ErrArgument}
+		}
+		for _, p := range fn.Params {
+			v, ok := named[p.Name]
+			delete(named, p.Name)
+			if !ok {
+				if p.Variadic {
+					continue
+				}
+				return nil, &TCallError{Func: fn.Name, Arg: -1, Reason: fmt.Sprintf("missing argument %q", p.Name), Err: ErrArity}
+			}
+			if !p.Variadic {
+				raw = append(raw, v)
+				continue
+			}
+			rest := make([]json.RawMessage, 0)
+			if err := json.Unmarshal(v, &rest); err != nil {
+				return nil, &TCallError{Func: fn.Name, Arg: len(raw), Reason: fmt.Sprintf("variadic %q must be an array", p.Name), Err: ErrArgument}
+			}
+			raw = append(raw, rest...)
+		}
+		if len(named) > 0 {
+			unknown := make([]string, 0, len(named))
+			for k := range named {
+				unknown = append(unknown, k)
+			}
+			sort.Strings(unknown)
+			return nil, &TCallError{Func: fn.Name, Arg: -1, Reason: fmt.Sprintf("unknown arguments %q", unknown), Err: ErrArity}
+		}
+	default:
+		return nil, &TCallError{Func: fn.Name, Arg: -1, Reason: "arguments must be an array or an object", Err: ErrArgument}
+	}
+	values := make([]interface{}, 0, len(raw))
+	for i, v := range raw {
+		var t reflect.Type
+		switch n := len(fn.Params); {
+		case n > 0 && fn.Params[n-1].Variadic && i >= n-1:
+			t = fn.Params[n-1].t.Elem()
+		case i < n:
+			t = fn.Params[i].t
+		default:
+			return nil, &TCallError{Func: fn.Name, Arg: -1, Reason: fmt.Sprintf("expected %d, got %d", n, len(raw)), Err: ErrArity}
+		}
+		p := reflect.New(t)
+		if err := json.Unmarshal(v, p.Interface()); err != nil {
+			return nil, &TCallError{Func: fn.Name, Arg: i, Reason: err.Error(), Err: ErrArgument}
+		}
+		values = append(values, p.Elem().Interface())
+	}
+	return values, nil
+}
+
+// Func Зарегистрированная функция для прямого вызова (например, лямбдой функтора)
+func (fn *TFunction) Func() Lambda {
+	return fn.f
+}
+
+// Register (name, description: string, f: Lambda, params: ...string) Зарегистрировать функцию в общем реестре
+func Register(name, description string, f Lambda, params ...string) error {
+	return Functions.Register(name, description, f, params...)
+}
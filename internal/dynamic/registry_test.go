@@ -0,0 +1,185 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package dynamic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// registered Реестр с функциями для тестов
+func registered(t *testing.T) *TRegistry {
+	t.Helper()
+	r := Registry()
+	functions := []struct {
+		name   string
+		f      Lambda
+		params []string
+	}{
+		{"sum", func(a, b float64) float64 { return a + b }, []string{"a", "b"}},
+		{"join", func(sep string, parts ...string) string { return strings.Join(parts, sep) }, []string{"sep", "parts"}},
+		{"divide", func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a / b, nil
+		}, []string{"a", "b"}},
+		{"split", func(s string) (string, string) { return s[:1], s[1:] }, nil},
+		{"noop", func() {}, nil},
+		{"point", func(p struct{ X, Y int }) int { return p.X * p.Y }, []string{"p"}},
+		{"deadline", func(ctx context.Context, name string) bool {
+			_, ok := ctx.Deadline()
+			return ok
+		}, []string{"name"}},
+	}
+	for _, fn := range functions {
+		if err := r.Register(fn.name, "", fn.f, fn.params...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+func TestRegister(t *testing.T) {
+	r := registered(t)
+	join, ok := r.Lookup("join")
+	if !ok {
+		t.Fatal("join is not registered")
+	}
+	want := []TParam{{Name: "sep", Type: "string"}, {Name: "parts", Type: "...string", Variadic: true}}
+	for i := range want {
+		want[i].t = join.Params[i].t
+	}
+	if !reflect.DeepEqual(join.Params, want) || !reflect.DeepEqual(join.Results, []string{"string"}) {
+		t.Errorf("join %+v %v", join.Params, join.Results)
+	}
+	if split, _ := r.Lookup("split"); split.Params[0].Name != "arg0" || len(split.Results) != 2 {
+		t.Errorf("split %+v", split)
+	}
+	if deadline, _ := r.Lookup("deadline"); len(deadline.Params) != 1 || deadline.Params[0].Name != "name" {
+		t.Errorf("context is listed as a parameter: %+v", deadline.Params)
+	}
+	names := make([]string, 0)
+	for _, fn := range r.List() {
+		names = append(names, fn.Name)
+	}
+	if strings.Join(names, ",") != "deadline,divide,join,noop,point,split,sum" {
+		t.Errorf("List %v", names)
+	}
+	r.Unregister("noop")
+	if _, ok := r.Lookup("noop"); ok {
+		t.Error("noop is not unregistered")
+	}
+
+	var nilFunc func()
+	for _, f := range []Lambda{nil, 42, nilFunc} {
+		if err := r.Register("bad", "", f); !errors.Is(err, ErrNotFunction) {
+			t.Errorf("Register(%T) = %v", f, err)
+		}
+	}
+	if err := r.Register("sum", "", func(a int) int { return a }, "a", "b"); err == nil {
+		t.Error("more names than parameters are accepted")
+	}
+}
+
+func TestCallJSON(t *testing.T) {
+	r := registered(t)
+	cases := []struct {
+		name, function, args string
+		want                 string
+		err                  error
+		reason               string
+	}{
+		{"positional", "sum", `[1, 2.5]`, `3.5`, nil, ""},
+		{"named", "sum", `{"b": 2, "a": 1}`, `3`, nil, ""},
+		{"variadic positional", "join", `["-", "a", "b"]`, `"a-b"`, nil, ""},
+		{"variadic named", "join", `{"sep": "+", "parts": ["a", "b", "c"]}`, `"a+b+c"`, nil, ""},
+		{"variadic omitted", "join", `{"sep": "+"}`, `""`, nil, ""},
+		{"variadic not an array", "join", `{"sep": "+", "parts": "a"}`, "", ErrArgument, `variadic "parts" must be an array`},
+		{"several results", "split", `["abc"]`, `["a","bc"]`, nil, ""},
+		{"no results", "noop", `null`, `null`, nil, ""},
+		{"no arguments", "noop", ``, `null`, nil, ""},
+		{"struct", "point", `[{"X": 2, "Y": 3}]`, `6`, nil, ""},
+		{"error result", "divide", `[1, 0]`, "", nil, "division by zero"},
+		{"error result nil", "divide", `{"a": 6, "b": 3}`, `2`, nil, ""},
+		{"context", "deadline", `["x"]`, `false`, nil, ""},
+		{"unknown function", "missing", `[]`, "", ErrNotRegistered, "unknown function"},
+		{"missing argument", "sum", `{"a": 1}`, "", ErrArity, `missing argument "b"`},
+		{"unknown argument", "sum", `{"a": 1, "b": 2, "c": 3, "d": 4}`, "", ErrArity, `unknown arguments ["c" "d"]`},
+		{"too many", "sum", `[1, 2, 3]`, "", ErrArity, "expected 2, got 3"},
+		{"too few", "sum", `[1]`, "", ErrArity, "expected 2, got 1"},
+		{"type mismatch", "sum", `[1, "2"]`, "", ErrArgument, "cannot unmarshal string into Go value of type float64"},
+		{"fraction into int", "divide", `[1.5, 1]`, "", ErrArgument, "cannot unmarshal number 1.5 into Go value of type int"},
+		{"malformed array", "sum", `[1,`, "", ErrArgument, "unexpected end of JSON input"},
+		{"scalar", "sum", `1`, "", ErrArgument, "arguments must be an array or an object"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := r.CallJSON(c.function, json.RawMessage(c.args))
+			switch {
+			case c.err != nil:
+				if !errors.Is(err, c.err) || !strings.Contains(err.Error(), c.reason) {
+					t.Errorf("error %v, want %v: %v", err, c.err, c.reason)
+				}
+			case c.reason != "":
+				var e *TCallError
+				if err == nil || errors.As(err, &e) || err.Error() != c.reason {
+					t.Errorf("error %v, want the error result %q", err, c.reason)
+				}
+			case err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case string(result) != c.want:
+				t.Errorf("result %s, want %s", result, c.want)
+			}
+		})
+	}
+}
+
+func TestCallJSONContext(t *testing.T) {
+	r := registered(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+	if _, err := r.CallJSONContext(ctx, "sum", json.RawMessage(`[1, 2]`)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expired context: %v", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	if result, err := r.CallJSONContext(ctx, "deadline", json.RawMessage(`{"name": "x"}`)); err != nil || string(result) != "true" {
+		t.Errorf("deadline = %s, %v", result, err)
+	}
+	result, err := r.Call("divide", 7, 2)
+	if err != nil || fmt.Sprint(result) != "[3 <nil>]" {
+		t.Errorf("Call = %v, %v", result, err)
+	}
+	if _, err := r.Call("missing"); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("Call(missing) = %v", err)
+	}
+}
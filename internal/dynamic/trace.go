@@ -0,0 +1,226 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package dynamic
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/x-research-team/bus"
+)
+
+// DefaultMaxLength Длина значения в трассировке по умолчанию
+const DefaultMaxLength = 64
+
+// TTraceConfig Настройки трассировки вызовов (log.json: trace)
+type TTraceConfig struct {
+	Enabled   bool     `json:"enabled"`
+	Sample    *float64 `json:"sample,omitempty"`     // Доля трассируемых вызовов от 0 до 1, по умолчанию 1; 0 не трассирует ничего
+	Arguments bool     `json:"arguments,omitempty"`  // Записывать аргументы и результаты
+	MaxLength int      `json:"max_length,omitempty"` // Длина значения, после которой оно обрезается
+	Redact    []string `json:"redact,omitempty"`     // Регулярные выражения фрагментов значений, заменяемых на ***
+	Hide      []string `json:"hide,omitempty"`       // Регулярные выражения имен функций, аргументы которых не записываются
+}
+
+// Validate Проверить регулярные выражения настроек
+func (c *TTraceConfig) Validate() error {
+	_, err := c.compile()
+	return err
+}
+
+// TSpan Трассировка одного вызова
+type TSpan struct {
+	Time     time.Time
+	Func     string
+	Caller   string // Функция и строка, из которой сделан вызов
+	Duration time.Duration
+	Error    bool
+	Err      string
+	Args     []string // Аргументы и результаты, если они записываются
+	Results  []string
+}
+
+func (s *TSpan) String() string {
+	status := "ok"
+	if s.Error {
+		status = "error: " + s.Err
+	}
+	return fmt.Sprintf("[Command] %v(%v) %v %v from %v: %v", s.Func, strings.Join(s.Args, ", "), s.Results, s.Duration, s.Caller, status)
+}
+
+// tTracer Скомпилированные настройки трассировки
+type tTracer struct {
+	sample    float64
+	arguments bool
+	length    int
+	redact    []*regexp.Regexp
+	hide      []*regexp.Regexp
+}
+
+var (
+	tracing int32
+	tracer  atomic.Value // *tTracer
+	sink    atomic.Value // func(*TSpan)
+
+	pkg = reflect.TypeOf(TSpan{}).PkgPath() + "."
+)
+
+func init() {
+	tracer.Store(&tTracer{sample: 1, length: DefaultMaxLength})
+	sink.Store(func(s *TSpan) { bus.Info <- s.String() })
+}
+
+// Trace Включить или выключить трассировку вызовов на ходу
+func Trace(t bool) {
+	v := int32(0)
+	if t {
+		v = 1
+	}
+	atomic.StoreInt32(&tracing, v)
+}
+
+// Tracing (c: *TTraceConfig) Применить настройки трассировки на ходу; nil выключает трассировку
+func Tracing(c *TTraceConfig) error {
+	if c == nil {
+		Trace(false)
+		return nil
+	}
+	t, err := c.compile()
+	if err != nil {
+		return err
+	}
+	tracer.Store(t)
+	Trace(c.Enabled)
+	return nil
+}
+
+// TraceTo (f: func(*TSpan)) Отправлять трассировки в f вместо bus.Info
+func TraceTo(f func(*TSpan)) {
+	if f == nil {
+		f = func(s *TSpan) { bus.Info <- s.String() }
+	}
+	sink.Store(f)
+}
+
+func (c *TTraceConfig) compile() (*tTracer, error) {
+	t := &tTracer{sample: 1, arguments: c.Arguments, length: c.MaxLength}
+	if c.Sample != nil {
+		if *c.Sample < 0 || *c.Sample > 1 {
+			return nil, fmt.Errorf("trace: sample %v is out of [0, 1]", *c.Sample)
+		}
+		t.sample = *c.Sample
+	}
+	if t.length <= 0 {
+		t.length = DefaultMaxLength
+	}
+	for _, list := range []struct {
+		patterns []string
+		to       *[]*regexp.Regexp
+	}{{c.Redact, &t.redact}, {c.Hide, &t.hide}} {
+		for _, p := range list.patterns {
+			r, err := regexp.Compile(p)
+			if err != nil {
+				return nil, fmt.Errorf("trace: %v", err)
+			}
+			*list.to = append(*list.to, r)
+		}
+	}
+	return t, nil
+}
+
+// traced Трассировать ли очередной вызов с учетом доли выборки
+func traced() bool {
+	if atomic.LoadInt32(&tracing) == 0 {
+		return false
+	}
+	t := tracer.Load().(*tTracer)
+	return t.sample >= 1 || t.sample > 0 && rand.Float64() < t.sample
+}
+
+// record Отправить трассировку вызова, начатого в start. Ошибкой считается и последний результат типа error
+func record(f interface{}, args, result []interface{}, err error, start time.Time) {
+	t := tracer.Load().(*tTracer)
+	s := &TSpan{Time: start, Func: funcName(f), Caller: caller(), Duration: time.Since(start)}
+	if n := len(result); err == nil && n > 0 {
+		err, _ = result[n-1].(error)
+	}
+	if s.Error = err != nil; s.Error {
+		s.Err = t.value(err.Error())
+	}
+	if t.arguments && !t.hidden(s.Func) {
+		s.Args = t.values(args)
+		s.Results = t.values(result)
+	}
+	sink.Load().(func(*TSpan))(s)
+}
+
+func (t *tTracer) hidden(name string) bool {
+	for _, r := range t.hide {
+		if r.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *tTracer) values(list []interface{}) []string {
+	values := make([]string, 0, len(list))
+	for _, v := range list {
+		values = append(values, t.value(fmt.Sprint(v)))
+	}
+	return values
+}
+
+// value Скрыть фрагменты по правилам и обрезать значение до заданной длины
+func (t *tTracer) value(s string) string {
+	for _, r := range t.redact {
+		s = r.ReplaceAllString(s, "***")
+	}
+	if utf8.RuneCountInString(s) > t.length {
+		s = string([]rune(s)[:t.length]) + "…"
+	}
+	return s
+}
+
+// caller Первая функция стека за пределами пакета dynamic
+func caller() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, pkg) {
+			return fmt.Sprintf("%s:%d", frame.Function, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package dynamic
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// spans Включить трассировку с настройками c и собирать трассировки в список
+func spans(t *testing.T, c *TTraceConfig) *[]*TSpan {
+	t.Helper()
+	var (
+		m    sync.Mutex
+		list []*TSpan
+	)
+	if err := Tracing(c); err != nil {
+		t.Fatal(err)
+	}
+	TraceTo(func(s *TSpan) {
+		m.Lock()
+		defer m.Unlock()
+		list = append(list, s)
+	})
+	t.Cleanup(func() {
+		Tracing(&TTraceConfig{})
+		TraceTo(nil)
+	})
+	return &list
+}
+
+// rate Указатель на долю выборки
+func rate(v float64) *float64 { return &v }
+
+func login(user, password string) bool { return password != "" }
+
+func TestTraceSample(t *testing.T) {
+	add := func(a, b int) int { return a + b }
+	cases := []struct {
+		name   string
+		sample *float64
+		want   int
+	}{
+		{"default", nil, 100},
+		{"all", rate(1), 100},
+		{"none", rate(0), 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			list := spans(t, &TTraceConfig{Enabled: true, Sample: c.sample})
+			for i := 0; i < 100; i++ {
+				if _, err := CallE(add, i, 1); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(*list) != c.want {
+				t.Errorf("traced %d calls, want %d", len(*list), c.want)
+			}
+		})
+	}
+	list := spans(t, &TTraceConfig{Enabled: false})
+	CallE(add, 1, 2)
+	if len(*list) != 0 {
+		t.Error("disabled tracing records calls")
+	}
+	for _, sample := range []float64{-0.1, 1.5} {
+		if err := (&TTraceConfig{Sample: rate(sample)}).Validate(); err == nil {
+			t.Errorf("sample %v is accepted", sample)
+		}
+	}
+	if err := (&TTraceConfig{Redact: []string{"("}}).Validate(); err == nil {
+		t.Error("invalid redact pattern is accepted")
+	}
+}
+
+func TestTraceArguments(t *testing.T) {
+	list := spans(t, &TTraceConfig{
+		Enabled:   true,
+		Arguments: true,
+		MaxLength: 12,
+		Redact:    []string{`(?i)password=\S+`, `s3cr3t`},
+		Hide:      []string{`\.login$`},
+	})
+	echo := func(s string) string { return s }
+	calls := []struct {
+		f    Lambda
+		args []interface{}
+	}{
+		{echo, []interface{}{"password=hunter2"}},
+		{echo, []interface{}{"key s3cr3t"}},
+		{echo, []interface{}{strings.Repeat("я", 20)}},
+		{login, []interface{}{"root", "s3cr3t"}},
+	}
+	for _, c := range calls {
+		if _, err := CallE(c.f, c.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*list) != len(calls) {
+		t.Fatalf("spans %v", *list)
+	}
+	want := []string{"***", "key ***", strings.Repeat("я", 12) + "…"}
+	for i, w := range want {
+		s := (*list)[i]
+		if len(s.Args) != 1 || s.Args[0] != w || len(s.Results) != 1 || s.Results[0] != w {
+			t.Errorf("span %d: args %q, results %q, want %q", i, s.Args, s.Results, w)
+		}
+	}
+	if s := (*list)[3]; s.Args != nil || s.Results != nil || !strings.HasSuffix(s.Func, ".login") {
+		t.Errorf("hidden function records arguments: %+v", s)
+	}
+	for _, s := range *list {
+		if strings.Contains(s.String(), "hunter2") || strings.Contains(s.String(), "s3cr3t") {
+			t.Errorf("span shows a secret: %v", s)
+		}
+	}
+}
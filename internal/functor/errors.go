@@ -0,0 +1,223 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package functor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/x-research-team/kernel/internal/dynamic"
+)
+
+// TErrorPolicy Поведение оператора при ошибке элемента
+type TErrorPolicy int
+
+const (
+	// Skip Пропустить элемент и запомнить ошибку (по умолчанию)
+	Skip TErrorPolicy = iota
+	// FailFast Прервать оператор на первой ошибке; коллекция не меняется
+	FailFast
+	// Replace Использовать вместо результата значение по умолчанию (см. OnError)
+	Replace
+)
+
+// TElementError Ошибка обработки элемента коллекции
+type TElementError struct {
+	Operator string      `json:"operator"`
+	Index    int         `json:"index"` // Индекс элемента в операции или -1, если ошибка не относится к элементу
+	Value    interface{} `json:"value,omitempty"`
+	Panic    bool        `json:"panic,omitempty"` // Лямбда завершилась паникой
+	Err      error       `json:"-"`
+}
+
+func (e *TElementError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("%v: %v", e.Operator, e.Err)
+	}
+	return fmt.Sprintf("%v: element %d (%v): %v", e.Operator, e.Index, e.Value, e.Err)
+}
+
+func (e *TElementError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON Ошибка с текстом причины
+func (e *TElementError) MarshalJSON() ([]byte, error) {
+	type element TElementError
+	return json.Marshal(&struct {
+		*element
+		Error string `json:"error"`
+	}{(*element)(e), e.Err.Error()})
+}
+
+// TErrors Ошибки функтора
+type TErrors []*TElementError
+
+func (errs TErrors) Error() string {
+	s := make([]string, 0, len(errs))
+	for _, err := range errs {
+		s = append(s, err.Error())
+	}
+	return fmt.Sprintf("[ERR] %v", strings.Join(s, ", "))
+}
+
+// OnError (policy: TErrorPolicy, value: ...any) Поведение операторов при ошибке элемента: вызов
+// с ошибкой или паникой, ненулевая ошибка последним результатом, не bool результат условия.
+// value - значение для Replace (для условий - истинность)
+func (functor *Functor) OnError(policy TErrorPolicy, value ...interface{}) *Functor {
+	functor.policy = policy
+	functor.replacement = nil
+	if len(value) > 0 {
+		functor.replacement = value[0]
+	}
+	return functor
+}
+
+// Errors Ошибки операторов функтора по порядку
+func (functor *Functor) Errors() []*TElementError {
+	return append([]*TElementError{}, functor.errors...)
+}
+
+// fail Запомнить ошибку, не относящуюся к элементу
+func (functor *Functor) fail(operator string, err error) {
+	functor.errors = append(functor.errors, &TElementError{Operator: operator, Index: -1, Err: err})
+}
+
+// tOperation Выполнение одного оператора: ошибки его элементов и признак прерывания
+type tOperation struct {
+	functor *Functor
+	name    string
+	m       sync.Mutex
+	errors  []*TElementError
+	failed  int32
+	stage   *TStage
+	start   time.Time
+	memory  *tMemory // Выделения памяти процессом к началу оператора (см. Profile)
+}
+
+func (functor *Functor) operation(name string) *tOperation {
+	op := &tOperation{functor: functor, name: name, stage: &TStage{Operator: name, In: len(functor.collection)}}
+	if functor.profile {
+		op.memory = allocated()
+	}
+	op.start = time.Now()
+	return op
+}
+
+// stopped Прерван ли оператор политикой FailFast или отменой контекста
+func (op *tOperation) stopped() bool {
+	return atomic.LoadInt32(&op.failed) == 1 || op.functor.ctx.Err() != nil
+}
+
+// invoke Вызвать лямбду в контексте ctx; ненулевая ошибка последним результатом возвращается как ошибка вызова
+func invoke(ctx context.Context, f dynamic.Lambda, args ...interface{}) ([]interface{}, error) {
+	result, err := dynamic.CallContext(ctx, f, args...)
+	if err != nil {
+		return nil, err
+	}
+	return split(f, result)
+}
+
+// condition Результат лямбды-условия; не bool результат - ошибка
+func condition(result []interface{}, err error) (bool, error) {
+	if _, ok := first(result).(bool); err == nil && !ok {
+		err = fmt.Errorf("condition returned %T, expected bool", first(result))
+	}
+	return truth(result), err
+}
+
+// call Вызвать лямбду для элемента index со значением value. false - результата нет:
+// элемент пропущен, оператор прерван или контекст отменен
+func (op *tOperation) call(index int, value interface{}, f dynamic.Lambda, args ...interface{}) ([]interface{}, bool) {
+	if op.stopped() {
+		return nil, false
+	}
+	result, err := invoke(op.functor.ctx, f, args...)
+	switch {
+	case err == nil:
+		return result, true
+	case dynamic.Cancelled(err):
+		return nil, false
+	}
+	return op.fail(index, value, err)
+}
+
+// test Вызвать лямбду-условие для элемента. false вторым значением - результата нет (см. call)
+func (op *tOperation) test(index int, value interface{}, f dynamic.Lambda) (bool, bool) {
+	if op.stopped() {
+		return false, false
+	}
+	match, err := condition(invoke(op.functor.ctx, f, value))
+	switch {
+	case err == nil:
+		return match, true
+	case dynamic.Cancelled(err):
+		return false, false
+	}
+	result, ok := op.fail(index, value, err)
+	return truth(result), ok
+}
+
+// fail Запомнить ошибку элемента и применить политику функтора
+func (op *tOperation) fail(index int, value interface{}, err error) ([]interface{}, bool) {
+	op.m.Lock()
+	op.errors = append(op.errors, &TElementError{Operator: op.name, Index: index, Value: value, Panic: errors.Is(err, dynamic.ErrPanic), Err: err})
+	op.m.Unlock()
+	switch op.functor.policy {
+	case Replace:
+		return []interface{}{op.functor.replacement}, true
+	case FailFast:
+		atomic.StoreInt32(&op.failed, 1)
+	}
+	return nil, false
+}
+
+// finish Перенести ошибки оператора в ошибки функтора. false, если оператор прерван
+func (functor *Functor) finish(op *tOperation) bool {
+	sort.SliceStable(op.errors, func(i, j int) bool { return op.errors[i].Index < op.errors[j].Index })
+	functor.errors = append(functor.errors, op.errors...)
+	functor.measure(op)
+	return !functor.done() && atomic.LoadInt32(&op.failed) == 0
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// split Отделить последний результат типа error
+func split(f interface{}, result []interface{}) ([]interface{}, error) {
+	t := reflect.TypeOf(f)
+	n := len(result)
+	if n == 0 || t == nil || t.Kind() != reflect.Func || t.NumOut() != n || t.Out(n-1) != errorType {
+		return result, nil
+	}
+	err, _ := result[n-1].(error)
+	return result[:n-1], err
+}
@@ -0,0 +1,162 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package functor
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/x-research-team/bus"
+)
+
+var errOdd = errors.New("odd element")
+
+// half Половина четного элемента; нечетный - ошибка
+func half(e interface{}) (interface{}, error) {
+	if e.(int)%2 != 0 {
+		return nil, errOdd
+	}
+	return e.(int) / 2, nil
+}
+
+// silent Проверить, что в bus.Error ничего не отправлено
+func silent(t *testing.T) {
+	t.Helper()
+	previous := bus.Error
+	bus.Error = make(bus.TError, 8)
+	t.Cleanup(func() {
+		select {
+		case err := <-bus.Error:
+			t.Errorf("error sent to bus.Error: %v", err)
+		default:
+		}
+		bus.Error = previous
+	})
+}
+
+func TestErrorPolicies(t *testing.T) {
+	silent(t)
+	for _, mode := range []FunctionMode{Sync, Async} {
+		f := F(1, 2, 3, 4).Map(mode, half)
+		equal(t, f.Result().Root, []interface{}{1, 2})
+		errs := f.Errors()
+		if len(errs) != 2 || errs[0].Index != 0 || errs[0].Value != 1 || errs[1].Index != 2 || !errors.Is(errs[1], errOdd) {
+			t.Fatalf("Skip errors %v", TErrors(errs))
+		}
+
+		f = F(1, 2, 3, 4).OnError(Replace, 0).Map(mode, half)
+		equal(t, f.Result().Root, []interface{}{0, 1, 0, 2})
+
+		f = F(1, 2, 3, 4).OnError(FailFast).Map(mode, half)
+		equal(t, f.Result().Root, []interface{}{1, 2, 3, 4})
+		if len(f.Errors()) == 0 || len(f.History()) != 1 {
+			t.Fatalf("FailFast recorded %d errors and %d steps", len(f.Errors()), len(f.History()))
+		}
+	}
+}
+
+func TestConditionErrors(t *testing.T) {
+	silent(t)
+	text := func(e interface{}) interface{} { return "yes" }
+	f := F(1, 2).Filter(Sync, text)
+	equal(t, f.Result().Root, []interface{}{})
+	if errs := f.Errors(); len(errs) != 2 || !strings.Contains(errs[0].Error(), "condition returned string") {
+		t.Fatalf("errors %v", TErrors(errs))
+	}
+	equal(t, F(1, 2).OnError(Replace, true).Filter(Sync, text).Result().Root, []interface{}{1, 2})
+
+	boom := func(e interface{}) interface{} { panic("boom") }
+	errs := F(1).Map(Sync, boom).Errors()
+	if len(errs) != 1 || !errs[0].Panic || !strings.Contains(errs[0].Error(), "boom") {
+		t.Fatalf("panic recorded as %v", TErrors(errs))
+	}
+}
+
+func TestPipeErrors(t *testing.T) {
+	silent(t)
+	f, err := F(1, 2, 3).Map(Sync, half).Pipe(func(xs ...interface{}) (interface{}, error) {
+		return nil, errors.New("pipe failed")
+	}, func(xs ...interface{}) int {
+		return len(xs)
+	})
+	var errs TErrors
+	if !errors.As(err, &errs) || len(errs) != 3 || errs[2].Operator != "Pipe" || errs[2].Index != -1 {
+		t.Fatalf("Pipe error %v", err)
+	}
+	equal(t, f.Result().Root, []interface{}{1})
+
+	buffer, err := json.Marshal(errs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buffer) != `{"operator":"Map","index":0,"value":1,"error":"odd element"}` {
+		t.Fatalf("JSON %s", buffer)
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	silent(t)
+	collection, err := S(1, 2, 3, 4).Map(half).Collect()
+	equal(t, collection, []interface{}{1, 2})
+	var errs TErrors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Operator != "Map" || errs[1].Index != 2 {
+		t.Fatalf("Skip error %v", err)
+	}
+
+	collection, err = F(1, 2, 3, 4).OnError(Replace, 0).Stream().Map(half).Collect()
+	equal(t, collection, []interface{}{0, 1, 0, 2})
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("Replace error %v", err)
+	}
+
+	n := 0
+	endless := Generate(func() (interface{}, bool) {
+		n++
+		return n, true
+	})
+	if _, err := endless.OnError(FailFast).Filter(func(e interface{}) bool {
+		if e.(int) == 3 {
+			panic("three")
+		}
+		return true
+	}).Collect(); !errors.As(err, &errs) || len(errs) != 1 || !errs[0].Panic || errs[0].Operator != "Filter" {
+		t.Fatalf("FailFast error %v", err)
+	}
+
+	var seen []interface{}
+	err = S(1, 2).ForEach(func(e interface{}) error {
+		seen = append(seen, e)
+		return errOdd
+	})
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Operator != "ForEach" || len(seen) != 2 {
+		t.Fatalf("ForEach error %v", err)
+	}
+	keys, err := S(1, 2, 3).Distinct(half).Collect()
+	equal(t, keys, []interface{}{2})
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("Distinct error %v", err)
+	}
+}
@@ -24,7 +24,9 @@
 package functor
 
 import (
+	"runtime"
 	"sync"
+	"sync/atomic"
 
 	"github.com/x-research-team/kernel/internal/dynamic"
 )
@@ -40,81 +42,72 @@ func flatten(s []interface{}) (r []interface{}) {
 	return
 }
 
-func flattenAsync(s []interface{}) (r []interface{}) {
-	var wg sync.WaitGroup
-	for _, e := range s {
-		if i, ok := e.([]interface{}); ok {
-			r = append(r, flattenAsync(i)...)
-		} else {
-			wg.Add(1)
-			go func(wg *sync.WaitGroup, e interface{}) {
-				defer wg.Done()
-				r = append(r, e)
-			}(&wg, e)
-		}
+// parallel Выполнить f для индексов 0..n-1 не более чем в Parallel горутинах.
+// Индексы раздаются по порядку; после отмены контекста новые индексы не берутся
+func (functor *Functor) parallel(n int, f func(i int)) {
+	workers := functor.workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
 	}
-	wg.Wait()
-	return
-}
-
-func mapper(s []interface{}, f interface{}) (r []interface{}) {
-	for _, e := range s {
-		if i, ok := e.([]interface{}); ok {
-			r = append(r, mapper(i, f)...)
-		} else {
-			r = append(r, dynamic.Call(f, e)...)
-		}
+	if workers > n {
+		workers = n
 	}
-	return
-}
-
-func mapperAsync(s []interface{}, f interface{}) (r []interface{}) {
-	var wg sync.WaitGroup
-	for _, e := range s {
-		if i, ok := e.([]interface{}); ok {
-			r = append(r, mapperAsync(i, f)...)
-		} else {
-			wg.Add(1)
-			go func(wg *sync.WaitGroup, e interface{}) {
-				defer wg.Done()
-				r = append(r, dynamic.Call(f, e)...)
-			}(&wg, e)
-		}
+	var (
+		wg   sync.WaitGroup
+		next int64 = -1
+	)
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= n || functor.ctx.Err() != nil {
+					return
+				}
+				f(i)
+			}
+		}()
 	}
 	wg.Wait()
-	return
 }
 
-func filter(s []interface{}, f interface{}) (r []interface{}) {
-	for _, e := range s {
-		if i, ok := e.([]interface{}); ok {
-			r = append(r, filter(i, f)...)
-		} else {
-			result := dynamic.Call(f, e)
-			if result[0].(bool) {
-				r = append(r, e)
-			}
+// each Вызвать лямбду для каждого элемента s; в режиме Async пулом горутин.
+// Результаты и признаки их наличия лежат по индексам элементов
+func (op *tOperation) each(mode FunctionMode, s []interface{}, f dynamic.Lambda) ([][]interface{}, []bool) {
+	results, ok := make([][]interface{}, len(s)), make([]bool, len(s))
+	run := func(i int) {
+		results[i], ok[i] = op.call(i, s[i], f, s[i])
+	}
+	if mode == Async {
+		op.functor.parallel(len(s), run)
+		return results, ok
+	}
+	for i := range s {
+		if op.stopped() {
+			break
 		}
+		run(i)
 	}
-	return
+	return results, ok
 }
 
-func filterAsync(s []interface{}, f interface{}) (r []interface{}) {
-	var wg sync.WaitGroup
-	for _, e := range s {
-		if i, ok := e.([]interface{}); ok {
-			r = append(r, filterAsync(i, f)...)
-		} else {
-			wg.Add(1)
-			go func(wg *sync.WaitGroup, e interface{}) {
-				defer wg.Done()
-				result := dynamic.Call(f, e)
-				if result[0].(bool) {
-					r = append(r, e)
-				}
-			}(&wg, e)
+// tests Проверить условие для каждого элемента s; в режиме Async пулом горутин.
+// Элемент подходит, если условие истинно; элементы без результата не подходят
+func (op *tOperation) tests(mode FunctionMode, s []interface{}, f dynamic.Lambda) (match []bool, ok []bool) {
+	match, ok = make([]bool, len(s)), make([]bool, len(s))
+	run := func(i int) {
+		match[i], ok[i] = op.test(i, s[i], f)
+	}
+	if mode == Async {
+		op.functor.parallel(len(s), run)
+		return
+	}
+	for i := range s {
+		if op.stopped() {
+			break
 		}
+		run(i)
 	}
-	wg.Wait()
 	return
 }
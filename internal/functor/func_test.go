@@ -0,0 +1,131 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package functor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func numbers(n int) []interface{} {
+	s := make([]interface{}, n)
+	for i := range s {
+		s[i] = i
+	}
+	return s
+}
+
+func TestAsyncKeepsOrder(t *testing.T) {
+	const workers = 4
+	var running, peak int32
+	double := func(e interface{}) interface{} {
+		if n := atomic.AddInt32(&running, 1); n > atomic.LoadInt32(&peak) {
+			atomic.StoreInt32(&peak, n)
+		}
+		time.Sleep(time.Duration(e.(int)%5) * 100 * time.Microsecond)
+		atomic.AddInt32(&running, -1)
+		return e.(int) * 2
+	}
+	even := func(e interface{}) bool { return e.(int)%4 == 0 }
+	f := F(numbers(200)...).Parallel(workers).Map(Async, double).Filter(Async, even)
+	if errs := f.Errors(); len(errs) != 0 {
+		t.Fatal(TErrors(errs))
+	}
+	root := f.Result().Root
+	if len(root) != 100 {
+		t.Fatalf("%d elements, want 100", len(root))
+	}
+	for i, e := range root {
+		if e != i*4 {
+			t.Fatalf("element %d = %v, want %d", i, e, i*4)
+		}
+	}
+	if peak > workers {
+		t.Fatalf("%d lambdas ran at once, Parallel(%d)", peak, workers)
+	}
+}
+
+func TestAsyncCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls int32
+	slow := func(_ context.Context, e interface{}) interface{} {
+		if atomic.AddInt32(&calls, 1) == 10 {
+			cancel()
+		}
+		time.Sleep(100 * time.Microsecond)
+		return e
+	}
+	f := F(numbers(1000)...).WithContext(ctx).Parallel(4).Map(Async, slow)
+	if n := atomic.LoadInt32(&calls); n >= 1000 {
+		t.Fatalf("all %d elements processed after cancellation", n)
+	}
+	root := f.Result().Root
+	if len(root) != 1000 || root[999] != 999 {
+		t.Fatal("cancelled Map changed the collection")
+	}
+	errs := f.Errors()
+	if len(errs) != 1 || errs[0].Operator != "Context" || !errors.Is(errs[0], context.Canceled) {
+		t.Fatalf("errors %v, want one context error", TErrors(errs))
+	}
+	if f.Map(Sync, func(e interface{}) interface{} { return 0 }).Result().Root[0] != 0 || len(f.Errors()) != 1 {
+		t.Fatal("operator ran on a cancelled functor")
+	}
+}
+
+// BenchmarkMap Sync и Async на вычислительной и на блокирующей лямбде
+func BenchmarkMap(b *testing.B) {
+	cpu := func(e interface{}) interface{} {
+		x := e.(int)
+		for i := 0; i < 2000; i++ {
+			x = x*31 + i
+		}
+		return x
+	}
+	blocking := func(e interface{}) interface{} {
+		time.Sleep(50 * time.Microsecond)
+		return e
+	}
+	for _, lambda := range []struct {
+		name     string
+		f        func(interface{}) interface{}
+		elements int
+	}{{"cpu", cpu, 1000}, {"blocking", blocking, 100}} {
+		s := numbers(lambda.elements)
+		for _, mode := range []struct {
+			name string
+			mode FunctionMode
+		}{{"Sync", Sync}, {"Async", Async}} {
+			b.Run(lambda.name+"/"+mode.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					F(s...).Parallel(16).Map(mode.mode, lambda.f)
+				}
+			})
+		}
+	}
+}
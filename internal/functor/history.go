@@ -0,0 +1,203 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package functor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// tStep Шаг истории: коллекция после операции
+type tStep struct {
+	index      int
+	operator   string
+	collection []interface{}
+}
+
+// tKeep Ограничения истории
+type tKeep struct {
+	off      bool // Хранить только текущий шаг
+	steps    int  // Число прошлых шагов, 0 - без ограничения
+	elements int  // Число элементов во всех прошлых шагах, 0 - без ограничения
+}
+
+// TStep Шаг истории функтора
+type TStep struct {
+	Index       int      `json:"index"`
+	Operator    string   `json:"operator"`
+	Size        int      `json:"size"`
+	Checkpoints []string `json:"checkpoints,omitempty"`
+}
+
+// TDiff Различия коллекций двух шагов истории без учета порядка
+type TDiff struct {
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Added   []interface{} `json:"added"`
+	Removed []interface{} `json:"removed"`
+}
+
+// record Записать текущую коллекцию шагом operator и отбросить шаги сверх ограничений
+func (functor *Functor) record(operator string) {
+	step := &tStep{index: functor.steps, operator: operator, collection: functor.collection}
+	functor.steps++
+	functor.history = append(functor.history, step)
+	functor.trim()
+}
+
+// trim Отбросить самые старые прошлые шаги, пока история не уложится в ограничения
+func (functor *Functor) trim() {
+	if len(functor.history) == 0 {
+		return
+	}
+	if functor.keep.off {
+		functor.history = functor.history[len(functor.history)-1:]
+		return
+	}
+	elements := 0
+	for _, step := range functor.history[:len(functor.history)-1] {
+		elements += len(step.collection)
+	}
+	drop := 0
+	for past := len(functor.history) - 1; drop < past; drop++ {
+		if (functor.keep.steps <= 0 || past-drop <= functor.keep.steps) && (functor.keep.elements <= 0 || elements <= functor.keep.elements) {
+			break
+		}
+		elements -= len(functor.history[drop].collection)
+	}
+	if drop > 0 {
+		functor.history = append([]*tStep{}, functor.history[drop:]...)
+	}
+}
+
+// KeepHistory (steps, elements: int) Хранить не больше steps прошлых шагов и не больше elements
+// элементов в них; 0 снимает ограничение. Контрольные точки не ограничиваются
+func (functor *Functor) KeepHistory(steps, elements int) *Functor {
+	functor.keep = tKeep{steps: steps, elements: elements}
+	functor.trim()
+	return functor
+}
+
+// NoHistory Не хранить прошлые шаги, например для больших коллекций; Undo становится недоступен
+func (functor *Functor) NoHistory() *Functor {
+	functor.keep = tKeep{off: true}
+	functor.trim()
+	return functor
+}
+
+// History Шаги истории от самого старого из сохраненных до текущего
+func (functor *Functor) History() []TStep {
+	names := make(map[*tStep][]string)
+	for name, step := range functor.checkpoints {
+		names[step] = append(names[step], name)
+	}
+	history := make([]TStep, 0, len(functor.history))
+	for _, step := range functor.history {
+		sort.Strings(names[step])
+		history = append(history, TStep{Index: step.index, Operator: step.operator, Size: len(step.collection), Checkpoints: names[step]})
+	}
+	return history
+}
+
+// Undo (n: int) Вернуться на n шагов назад; отмененные шаги удаляются из истории
+func (functor *Functor) Undo(n int) *Functor {
+	if n <= 0 {
+		return functor
+	}
+	if past := len(functor.history) - 1; n > past {
+		functor.fail(fmt.Sprintf("Undo(%d)", n), fmt.Errorf("only %d steps in history", past))
+		return functor
+	}
+	functor.history = functor.history[:len(functor.history)-n]
+	functor.collection = functor.history[len(functor.history)-1].collection
+	return functor
+}
+
+// Checkpoint (name: string) Запомнить текущий шаг под именем name
+func (functor *Functor) Checkpoint(name string) *Functor {
+	if functor.checkpoints == nil {
+		functor.checkpoints = make(map[string]*tStep)
+	}
+	functor.checkpoints[name] = functor.history[len(functor.history)-1]
+	return functor
+}
+
+// Restore (name: string) Вернуть коллекцию контрольной точки name новым шагом истории
+func (functor *Functor) Restore(name string) *Functor {
+	step, ok := functor.checkpoints[name]
+	if !ok {
+		functor.fail(fmt.Sprintf("Restore(%s)", name), errors.New("unknown checkpoint"))
+		return functor
+	}
+	functor.collection = step.collection
+	functor.record(fmt.Sprintf("Restore(%s)", name))
+	return functor
+}
+
+// step Шаг с индексом index из истории или контрольных точек
+func (functor *Functor) step(index int) (*tStep, bool) {
+	for _, step := range functor.history {
+		if step.index == index {
+			return step, true
+		}
+	}
+	for _, step := range functor.checkpoints {
+		if step.index == index {
+			return step, true
+		}
+	}
+	return nil, false
+}
+
+// Diff (a, b: int) Элементы, добавленные и удаленные между шагами с индексами a и b (см. History)
+func (functor *Functor) Diff(a, b int) (*TDiff, error) {
+	from, ok := functor.step(a)
+	if !ok {
+		return nil, fmt.Errorf("diff: step %d is not in history", a)
+	}
+	to, ok := functor.step(b)
+	if !ok {
+		return nil, fmt.Errorf("diff: step %d is not in history", b)
+	}
+	diff := &TDiff{From: a, To: b, Added: make([]interface{}, 0), Removed: make([]interface{}, 0)}
+	count := make(map[interface{}]int)
+	for _, e := range from.collection {
+		count[hashable(e)]++
+	}
+	for _, e := range to.collection {
+		if k := hashable(e); count[k] > 0 {
+			count[k]--
+		} else {
+			diff.Added = append(diff.Added, e)
+		}
+	}
+	for _, e := range from.collection {
+		if k := hashable(e); count[k] > 0 {
+			count[k]--
+			diff.Removed = append(diff.Removed, e)
+		}
+	}
+	return diff, nil
+}
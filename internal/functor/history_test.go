@@ -0,0 +1,140 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package functor
+
+import (
+	"reflect"
+	"testing"
+)
+
+var (
+	double = func(e interface{}) interface{} { return e.(int) * 2 }
+	odd    = func(e interface{}) bool { return e.(int)%2 == 1 }
+)
+
+// operators Операторы шагов истории
+func operators(f *Functor) []string {
+	names := make([]string, 0)
+	for _, step := range f.History() {
+		names = append(names, step.Operator)
+	}
+	return names
+}
+
+func TestUndo(t *testing.T) {
+	f := F(1, 2, 3).Map(Sync, double).Filter(Sync, func(e interface{}) bool { return e.(int) > 2 })
+	if got := f.History(); !reflect.DeepEqual(got, []TStep{{0, "F", 3, nil}, {1, "Map", 3, nil}, {2, "Filter", 2, nil}}) {
+		t.Fatalf("History = %+v", got)
+	}
+	equal(t, root(t, f.Undo(0)), []interface{}{4, 6})
+	equal(t, root(t, f.Undo(1)), []interface{}{2, 4, 6})
+	equal(t, root(t, f.Map(Sync, double)), []interface{}{4, 8, 12})
+	if got := operators(f); !reflect.DeepEqual(got, []string{"F", "Map", "Map"}) {
+		t.Fatalf("operators after Undo = %v", got)
+	}
+	f.Undo(3)
+	if errs := f.Errors(); len(errs) != 1 || errs[0].Operator != "Undo(3)" {
+		t.Fatalf("errors %v, want Undo(3)", TErrors(errs))
+	}
+	if got := f.Result().Root; !reflect.DeepEqual(got, []interface{}{4, 8, 12}) {
+		t.Fatalf("failed Undo changed the collection: %v", got)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	f := F(1, 2, 3).Checkpoint("start").Map(Sync, double).Checkpoint("doubled").Filter(Sync, odd)
+	equal(t, root(t, f), []interface{}{})
+	equal(t, root(t, f.Restore("start")), []interface{}{1, 2, 3})
+	history := f.History()
+	if last := history[len(history)-1]; last.Operator != "Restore(start)" || last.Size != 3 {
+		t.Fatalf("last step %+v", last)
+	}
+	if !reflect.DeepEqual(history[0].Checkpoints, []string{"start"}) || !reflect.DeepEqual(history[1].Checkpoints, []string{"doubled"}) {
+		t.Fatalf("checkpoints in %+v", history)
+	}
+	f.Restore("missing")
+	if errs := f.Errors(); len(errs) != 1 || errs[0].Operator != "Restore(missing)" {
+		t.Fatalf("errors %v, want Restore(missing)", TErrors(errs))
+	}
+}
+
+func TestKeepHistory(t *testing.T) {
+	f := F(1, 2, 3).Checkpoint("start").KeepHistory(2, 0)
+	for i := 0; i < 4; i++ {
+		f.Map(Sync, double)
+	}
+	if got := operators(f); len(got) != 3 {
+		t.Fatalf("%d steps kept with KeepHistory(2, 0), want 3", len(got))
+	}
+	equal(t, root(t, f.Restore("start")), []interface{}{1, 2, 3})
+
+	f = F(1, 2, 3).KeepHistory(0, 4).Map(Sync, double).Map(Sync, double)
+	if got := f.History(); len(got) != 2 || got[0].Index != 1 {
+		t.Fatalf("History with 4 kept elements = %+v", got)
+	}
+
+	f = F(1, 2, 3).NoHistory().Map(Sync, double).Map(Sync, double)
+	if got := f.History(); len(got) != 1 || got[0].Index != 2 {
+		t.Fatalf("History without history = %+v", got)
+	}
+	f.Undo(1)
+	if len(f.Errors()) != 1 {
+		t.Fatal("Undo without history is not an error")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	f := F(1, 1, 2, 3).Checkpoint("start").Filter(Sync, odd).Map(Sync, func(e interface{}) interface{} {
+		if e == 3 {
+			return 4
+		}
+		return e
+	}).NoHistory()
+	diff, err := f.Diff(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(diff, &TDiff{From: 0, To: 2, Added: []interface{}{4}, Removed: []interface{}{2, 3}}) {
+		t.Fatalf("Diff = %+v", diff)
+	}
+	if _, err := f.Diff(0, 1); err == nil {
+		t.Fatal("Diff with a dropped step")
+	}
+
+	// Элементы с одинаковым текстовым видом, но разными типами не считаются равными
+	f = F([]interface{}{1}, map[string]interface{}{"a": 1.0}).Map(Sync, func(e interface{}) interface{} {
+		switch e.(type) {
+		case []interface{}:
+			return []interface{}{"1"}
+		default:
+			return map[string]interface{}{"a": "1"}
+		}
+	})
+	if diff, err = f.Diff(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Added) != 2 || len(diff.Removed) != 2 {
+		t.Fatalf("Diff of colliding values = %+v", diff)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package functor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/x-research-team/kernel/internal/dynamic"
+)
+
+// fork Копия функтора для ветки: коллекция, число горутин, политика ошибок, история и профилирование в контексте ctx
+func (functor *Functor) fork(ctx context.Context) *Functor {
+	c := F(functor.collection...).WithContext(ctx).Parallel(functor.workers).OnError(functor.policy, functor.replacement)
+	c.keep = functor.keep
+	c.profile = functor.profile
+	return c
+}
+
+// Fork (mode: FunctionMode, branches: ...func (F): F) Создать ветки исполнения. В режиме Async ветки
+// выполняются одновременно (не более Parallel) и Fork возвращается, когда завершены все.
+// Ветки добавляются в порядке аргументов
+func (functor *Functor) Fork(mode FunctionMode, branches ...func(*Functor) *Functor) *Functor {
+	op := functor.operation(fmt.Sprintf("Fork(%d)", len(branches)))
+	results := make([]*Functor, len(branches))
+	run := func(i int) {
+		results[i] = branches[i](functor.fork(functor.ctx))
+	}
+	if mode == Async {
+		functor.parallel(len(branches), run)
+	} else {
+		for i := range branches {
+			run(i)
+		}
+	}
+	for _, branch := range results {
+		if branch != nil {
+			functor.branches = append(functor.branches, branch)
+			if functor.profile {
+				op.stage.Branches = append(op.stage.Branches, branch.Stages())
+			}
+		}
+	}
+	functor.finish(op)
+	return functor
+}
+
+// adopt Перенести ошибки ветки в ошибки функтора
+func (functor *Functor) adopt(branch *Functor) {
+	functor.errors = append(functor.errors, branch.errors...)
+}
+
+// Merge Заменить коллекцию коллекциями веток по порядку; ветки и их ошибки переходят в функтор.
+// Без веток коллекция не меняется
+func (functor *Functor) Merge() *Functor {
+	if functor.done() || len(functor.branches) == 0 {
+		return functor
+	}
+	op := functor.operation("Merge")
+	collection := make([]interface{}, 0)
+	for _, branch := range functor.branches {
+		collection = append(collection, branch.collection...)
+		functor.adopt(branch)
+	}
+	functor.branches = nil
+	return functor.commit(op, collection)
+}
+
+// JoinBy (mode: FunctionMode, key: func(e any): any) Соединить элементы коллекции с элементами последней
+// ветки с равным ключом в TTuple{элемент, элемент ветки} (внутреннее соединение). Пары идут в порядке
+// элементов коллекции, затем ветки; ветка и ее ошибки переходят в функтор
+func (functor *Functor) JoinBy(mode FunctionMode, key dynamic.Lambda) *Functor {
+	if functor.done() {
+		return functor
+	}
+	if len(functor.branches) == 0 {
+		functor.fail("JoinBy", errors.New("no branch to join"))
+		return functor
+	}
+	branch := functor.branches[len(functor.branches)-1]
+	functor.branches = functor.branches[:len(functor.branches)-1]
+	functor.adopt(branch)
+	op := functor.operation("JoinBy")
+	rights, ok := op.each(mode, branch.collection, key)
+	index := make(map[interface{}][]interface{})
+	for i, e := range branch.collection {
+		if ok[i] {
+			k := hashable(first(rights[i]))
+			index[k] = append(index[k], e)
+		}
+	}
+	lefts, ok := op.each(mode, functor.collection, key)
+	collection := make([]interface{}, 0)
+	for i, e := range functor.collection {
+		if !ok[i] {
+			continue
+		}
+		for _, r := range index[hashable(first(lefts[i]))] {
+			collection = append(collection, TTuple{e, r})
+		}
+	}
+	return functor.commit(op, collection)
+}
+
+// Race (branches: ...func (F): F) Выполнить ветки одновременно и заменить коллекцию результатом ветки,
+// завершившейся первой; контекст остальных отменяется. Ошибки победившей ветки переходят в функтор
+func (functor *Functor) Race(branches ...func(*Functor) *Functor) *Functor {
+	return functor.race("Race", false, branches)
+}
+
+// First (branches: ...func (F): F) То же, что Race, но побеждает первая ветка, завершившаяся без ошибок.
+// Если таких нет, коллекция не меняется, а ошибки всех веток переходят в функтор
+func (functor *Functor) First(branches ...func(*Functor) *Functor) *Functor {
+	return functor.race("First", true, branches)
+}
+
+// race Выполнить ветки одновременно и взять результат первой завершившейся (clean - без ошибок)
+func (functor *Functor) race(name string, clean bool, branches []func(*Functor) *Functor) *Functor {
+	if functor.done() || len(branches) == 0 {
+		return functor
+	}
+	op := functor.operation(name)
+	ctx, cancel := context.WithCancel(functor.ctx)
+	defer cancel()
+	finished := make(chan *Functor, len(branches))
+	for _, branch := range branches {
+		go func(branch func(*Functor) *Functor) {
+			finished <- branch(functor.fork(ctx))
+		}(branch)
+	}
+	failed := make([]*Functor, 0, len(branches))
+	for range branches {
+		branch := <-finished
+		if functor.profile {
+			op.stage.Branches = append(op.stage.Branches, branch.Stages())
+		}
+		if clean && len(branch.errors) != 0 {
+			failed = append(failed, branch)
+			continue
+		}
+		cancel()
+		functor.adopt(branch)
+		return functor.commit(op, branch.collection)
+	}
+	for _, branch := range failed {
+		functor.adopt(branch)
+	}
+	op.fail(-1, nil, errors.New("no branch finished without errors"))
+	functor.finish(op)
+	return functor
+}
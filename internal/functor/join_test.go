@@ -0,0 +1,141 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package functor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// branchRoots Коллекции веток результата
+func branchRoots(f *Functor) [][]interface{} {
+	roots := make([][]interface{}, 0)
+	for _, b := range f.Result().Branches {
+		roots = append(roots, b.(*FunctorResult).Root)
+	}
+	return roots
+}
+
+func TestForkMerge(t *testing.T) {
+	for _, mode := range []FunctionMode{Sync, Async} {
+		f := F(1, 2, 3, 4).Fork(mode,
+			func(b *Functor) *Functor { return b.Map(Sync, double) },
+			func(b *Functor) *Functor { return b.Filter(Sync, odd) },
+		)
+		equal(t, root(t, f), []interface{}{1, 2, 3, 4})
+		if roots := branchRoots(f); len(roots) != 2 || len(roots[0]) != 4 || len(roots[1]) != 2 {
+			t.Fatalf("branches %v", roots)
+		}
+		equal(t, root(t, f.Merge()), []interface{}{2, 4, 6, 8, 1, 3})
+		if len(f.Result().Branches) != 0 {
+			t.Fatal("merged branches are kept")
+		}
+	}
+	equal(t, root(t, F(1).Merge()), []interface{}{1})
+
+	f := F(1, 2).OnError(Replace, 0).Branch(func(b *Functor) *Functor { return b.Map(Sync, half) }).Merge()
+	equal(t, f.Result().Root, []interface{}{0, 1})
+	if errs := f.Errors(); len(errs) != 1 || errs[0].Index != 0 {
+		t.Fatalf("branch errors are not merged: %v", TErrors(errs))
+	}
+}
+
+func TestJoinBy(t *testing.T) {
+	type order struct{ customer, id int }
+	customer := func(e interface{}) interface{} {
+		if o, ok := e.(order); ok {
+			return o.customer
+		}
+		return e
+	}
+	orders := []interface{}{order{1, 10}, order{2, 20}, order{1, 11}, order{3, 30}}
+	for _, mode := range []FunctionMode{Sync, Async} {
+		f := F(1, 2, 4).Branch(func(b *Functor) *Functor { return F(orders...) }).JoinBy(mode, customer)
+		equal(t, root(t, f), []interface{}{
+			TTuple{1, order{1, 10}},
+			TTuple{1, order{1, 11}},
+			TTuple{2, order{2, 20}},
+		})
+	}
+	values := colliding()
+	identity := func(e interface{}) interface{} { return e }
+	joined := root(t, F(values[0], values[3]).Branch(func(b *Functor) *Functor { return F(values[1:]...) }).JoinBy(Sync, identity))
+	equal(t, joined, []interface{}{TTuple{values[0], values[8]}, TTuple{values[3], values[3]}})
+	f := F(1).JoinBy(Sync, customer)
+	if errs := f.Errors(); len(errs) != 1 || errs[0].Operator != "JoinBy" {
+		t.Fatalf("JoinBy without branches: %v", TErrors(errs))
+	}
+}
+
+func TestRace(t *testing.T) {
+	started, cancelled := make(chan struct{}), make(chan bool, 1)
+	var once sync.Once
+	slow := func(ctx context.Context, e interface{}) interface{} {
+		once.Do(func() { close(started) })
+		select {
+		case <-ctx.Done():
+			cancelled <- true
+		case <-time.After(time.Second):
+			cancelled <- false
+		}
+		return e
+	}
+	f := F(1, 2).Race(
+		func(b *Functor) *Functor { return b.Map(Sync, slow) },
+		func(b *Functor) *Functor {
+			<-started
+			return b.Map(Sync, double)
+		},
+	)
+	equal(t, root(t, f), []interface{}{2, 4})
+	if !<-cancelled {
+		t.Fatal("losing branch is not cancelled")
+	}
+}
+
+func TestFirst(t *testing.T) {
+	late := func(e interface{}) interface{} {
+		time.Sleep(10 * time.Millisecond)
+		return e.(int) * 10
+	}
+	f := F(1, 2).First(
+		func(b *Functor) *Functor { return b.Map(Sync, half) },
+		func(b *Functor) *Functor { return b.Map(Sync, late) },
+	)
+	equal(t, root(t, f), []interface{}{10, 20})
+
+	f = F(1, 3).First(
+		func(b *Functor) *Functor { return b.Map(Sync, half) },
+		func(b *Functor) *Functor { return b.Map(Async, half) },
+	)
+	equal(t, f.Result().Root, []interface{}{1, 3})
+	errs := f.Errors()
+	if len(errs) != 5 || !strings.Contains(errs[4].Error(), "no branch finished without errors") || !errors.Is(errs[0], errOdd) {
+		t.Fatalf("errors %v", TErrors(errs))
+	}
+}
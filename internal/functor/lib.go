@@ -24,27 +24,35 @@
 package functor
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"strings"
-	"sync"
 
 	"github.com/x-research-team/kernel/internal/dynamic"
 )
 
 // Functor Функтор
 type Functor struct {
-	errors     []error
-	history    []*Functor
-	branches   []*Functor
-	collection []interface{}
+	ctx         context.Context
+	cancelled   bool
+	workers     int
+	errors      []*TElementError
+	policy      TErrorPolicy
+	replacement interface{}
+	history     []*tStep
+	steps       int
+	keep        tKeep
+	checkpoints map[string]*tStep
+	profile     bool
+	stages      []*TStage
+	branches    []*Functor
+	collection  []interface{}
 }
 
 // FunctionMode Тип исполнения
 type FunctionMode int
 
 const (
-	// Async Асинхронное исполнение (Experimental)
+	// Async Параллельное исполнение пулом горутин (см. Parallel) с сохранением порядка элементов
 	Async FunctionMode = iota
 	// Sync Синхронное исполнение
 	Sync
@@ -52,103 +60,144 @@ const (
 
 // F (args: ...any) Создать функтор
 func F(args ...interface{}) *Functor {
-	return &Functor{collection: args}
+	functor := &Functor{ctx: context.Background(), collection: args}
+	functor.record("F")
+	return functor
 }
 
-// Branch (branch: func (F): F) Создать ветку исполенения
-func (functor *Functor) Branch(branch func(*Functor) *Functor) *Functor {
-	functor.branches = append(functor.branches, branch(F(functor.collection...)))
+// WithContext (ctx: context.Context) Выполнять операции функтора и его новых веток в контексте ctx.
+// Лямбды, принимающие context.Context первым параметром, получают ctx. После отмены контекста
+// или истечения его срока операции не меняют коллекцию, а ошибка контекста попадает в ошибки функтора
+func (functor *Functor) WithContext(ctx context.Context) *Functor {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	functor.ctx = ctx
+	return functor
+}
+
+// Parallel (n: int) Число горутин для операций в режиме Async, по умолчанию GOMAXPROCS
+func (functor *Functor) Parallel(n int) *Functor {
+	functor.workers = n
 	return functor
 }
 
-func (functor *Functor) backup() {
-	c := F(functor.collection...)
-	c.errors = functor.errors
-	c.history = functor.history
-	functor.history = append(functor.history, c)
+// Context Контекст функтора
+func (functor *Functor) Context() context.Context {
+	return functor.ctx
+}
+
+// Branch (branch: func (F): F) Создать ветку исполенения (см. Fork)
+func (functor *Functor) Branch(branch func(*Functor) *Functor) *Functor {
+	return functor.Fork(Sync, branch)
+}
+
+// done Отменен ли контекст функтора; ошибка контекста запоминается один раз
+func (functor *Functor) done() bool {
+	err := functor.ctx.Err()
+	if err == nil {
+		return false
+	}
+	if !functor.cancelled {
+		functor.cancelled = true
+		functor.fail("Context", err)
+	}
+	return true
 }
 
+// Map (mode: FunctionMode, lambdas: ...Lambda) Заменить элементы результатами лямбд;
+// вложенные []interface{} раскрываются
 func (functor *Functor) Map(mode FunctionMode, lambdas ...dynamic.Lambda) *Functor {
-	functor.backup()
-	switch mode {
-	case Async:
-		for _, lambda := range lambdas {
-			functor.collection = mapperAsync(functor.collection, lambda)
-		}
-	case Sync:
-		for _, lambda := range lambdas {
-			functor.collection = mapper(functor.collection, lambda)
+	if functor.done() {
+		return functor
+	}
+	op := functor.operation("Map")
+	collection := functor.collection
+	for _, lambda := range lambdas {
+		s := flatten(collection)
+		results, ok := op.each(mode, s, lambda)
+		collection = make([]interface{}, 0, len(s))
+		for i, result := range results {
+			if ok[i] {
+				collection = append(collection, result...)
+			}
 		}
 	}
-	return functor
+	return functor.commit(op, collection)
 }
 
+// Filter (mode: FunctionMode, lambdas: ...Lambda) Оставить элементы, для которых условия истинны;
+// вложенные []interface{} раскрываются
 func (functor *Functor) Filter(mode FunctionMode, lambdas ...dynamic.Lambda) *Functor {
-	functor.backup()
-	switch mode {
-	case Async:
-		for _, lambda := range lambdas {
-			functor.collection = filterAsync(functor.collection, lambda)
-		}
-	case Sync:
-		for _, lambda := range lambdas {
-			functor.collection = filter(functor.collection, lambda)
+	if functor.done() {
+		return functor
+	}
+	op := functor.operation("Filter")
+	collection := functor.collection
+	for _, lambda := range lambdas {
+		s := flatten(collection)
+		match, _ := op.tests(mode, s, lambda)
+		collection = make([]interface{}, 0, len(s))
+		for i, e := range s {
+			if match[i] {
+				collection = append(collection, e)
+			}
 		}
 	}
+	return functor.commit(op, collection)
+}
+
+// commit Заменить коллекцию результатом оператора и записать шаг в историю,
+// если оператор не прерван и контекст не отменен во время него
+func (functor *Functor) commit(op *tOperation, collection []interface{}) *Functor {
+	if !functor.finish(op) {
+		return functor
+	}
+	functor.collection = collection
+	op.stage.Out = len(collection)
+	functor.record(op.name)
 	return functor
 }
 
-// Apply Применить обработчик к данным
+// Apply Применить обработчики к данным по очереди: каждый получает элементы коллекции аргументами
+// и возвращает новую коллекцию. Обработчики составляют цепочку, поэтому в режиме Async
+// они выполняются так же последовательно. Ошибка обработчика не относится к элементу (индекс -1);
+// при Skip коллекция остается прежней
 func (functor *Functor) Apply(mode FunctionMode, lambdas ...dynamic.Lambda) *Functor {
-	functor.backup()
-	switch mode {
-	case Async:
-		var (
-			wg sync.WaitGroup
-			m  sync.RWMutex
-		)
-		for _, lambda := range lambdas {
-			wg.Add(1)
-			go func(wg *sync.WaitGroup, lambda dynamic.Lambda) {
-				defer wg.Done()
-				m.Lock()
-				functor.collection = dynamic.Call(lambda, functor.collection)
-				m.Unlock()
-			}(&wg, lambda)
-		}
-		wg.Wait()
-	case Sync:
-		for _, lambda := range lambdas {
-			functor.collection = dynamic.Call(lambda, functor.collection...)
+	if functor.done() {
+		return functor
+	}
+	op := functor.operation("Apply")
+	collection := functor.collection
+	for _, lambda := range lambdas {
+		if result, ok := op.call(-1, nil, lambda, collection...); ok {
+			collection = result
 		}
 	}
-
-	return functor
+	return functor.commit(op, collection)
 }
 
-// Pipe (f: func (...any): []any) Выполнить команду в ветке исполнения
+// Pipe (f: func (...any): []any) Выполнить команду в ветке исполнения. Результаты-ошибки
+// попадают в ошибки функтора; если ошибки есть, они возвращаются как TErrors
 func (functor *Functor) Pipe(f ...interface{}) (*Functor, error) {
 	var returns []interface{}
+	op := functor.operation("Pipe")
 	for _, o := range f {
-		results := dynamic.Call(o, functor.collection...)
-		for i := range results {
-			switch results[i].(type) {
+		results, _ := op.call(-1, nil, o, functor.collection...)
+		for _, result := range results {
+			switch err := result.(type) {
 			case error:
-				functor.errors = append(functor.errors, results[i].(error))
-				results = remove(results, i)
+				op.fail(-1, nil, err)
 			case nil:
-				results = remove(results, i)
+			default:
+				returns = append(returns, result)
 			}
 		}
-		returns = append(returns, results...)
 	}
-	c := F(returns...)
+	functor.finish(op)
+	c := F(returns...).WithContext(functor.ctx)
 	if len(functor.errors) != 0 {
-		var errs []string
-		for _, err := range functor.errors {
-			errs = append(errs, err.Error())
-		}
-		return c, fmt.Errorf("[ERR] %v", strings.Join(errs, ", "))
+		return c, TErrors(functor.Errors())
 	}
 	return c, nil
 }
@@ -158,23 +207,23 @@ func (functor *Functor) Result() *FunctorResult {
 	for _, branch := range functor.branches {
 		branches = append(branches, branch.Result())
 	}
-	return &FunctorResult{
+	result := &FunctorResult{
 		Root:     functor.collection,
 		Branches: branches,
 	}
+	if functor.profile {
+		result.Stages = functor.Stages()
+	}
+	return result
 }
 
 type FunctorResult struct {
 	Root     []interface{}
 	Branches []interface{}
+	Stages   []*TStage `json:",omitempty"` // Статистика операторов, если включен Profile
 }
 
 func (r *FunctorResult) ToJson() json.RawMessage {
 	buffer, _ := json.Marshal(r)
 	return buffer
 }
-
-func remove(s []interface{}, i int) []interface{} {
-	s[i] = s[len(s)-1]
-	return s[:len(s)-1]
-}
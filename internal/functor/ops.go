@@ -0,0 +1,497 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package functor
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/x-research-team/kernel/internal/dynamic"
+)
+
+// TTuple Кортеж элементов (Zip, Chunk, Partition). Map и Filter не раскрывают его, как []interface{}
+type TTuple []interface{}
+
+// TGroup Группа элементов с одинаковым ключом (GroupBy)
+type TGroup struct {
+	Key   interface{}   `json:"key"`
+	Items []interface{} `json:"items"`
+}
+
+// first Первый результат вызова или nil
+func first(result []interface{}) interface{} {
+	if len(result) == 0 {
+		return nil
+	}
+	return result[0]
+}
+
+// truth Вернула ли лямбда-условие true
+func truth(result []interface{}) bool {
+	ok, _ := first(result).(bool)
+	return ok
+}
+
+// FlatMap (mode: FunctionMode, lambdas: ...Lambda) Как Map, но результаты-коллекции раскрываются на один уровень
+func (functor *Functor) FlatMap(mode FunctionMode, lambdas ...dynamic.Lambda) *Functor {
+	if functor.done() {
+		return functor
+	}
+	op := functor.operation("FlatMap")
+	collection := functor.collection
+	for _, lambda := range lambdas {
+		results, ok := op.each(mode, collection, lambda)
+		next := make([]interface{}, 0, len(collection))
+		for i, result := range results {
+			if !ok[i] {
+				continue
+			}
+			for _, v := range result {
+				switch items := v.(type) {
+				case []interface{}:
+					next = append(next, items...)
+				case TTuple:
+					next = append(next, items...)
+				default:
+					next = append(next, v)
+				}
+			}
+		}
+		collection = next
+	}
+	return functor.commit(op, collection)
+}
+
+// Reduce (f: func(acc, e any): any) Свернуть коллекцию в один элемент, начиная с первого.
+// При Skip элемент с ошибкой не меняет накопленное значение
+func (functor *Functor) Reduce(f dynamic.Lambda) *Functor {
+	if functor.done() {
+		return functor
+	}
+	op := functor.operation("Reduce")
+	if len(functor.collection) == 0 {
+		return functor.commit(op, functor.collection)
+	}
+	return functor.fold(op, functor.collection[0], 1, f)
+}
+
+// Fold (init: any, f: func(acc, e any): any) Свернуть коллекцию в один элемент, начиная с init
+func (functor *Functor) Fold(init interface{}, f dynamic.Lambda) *Functor {
+	if functor.done() {
+		return functor
+	}
+	return functor.fold(functor.operation("Fold"), init, 0, f)
+}
+
+// fold Свернуть элементы коллекции, начиная с индекса from
+func (functor *Functor) fold(op *tOperation, acc interface{}, from int, f dynamic.Lambda) *Functor {
+	for i := from; i < len(functor.collection) && !op.stopped(); i++ {
+		e := functor.collection[i]
+		if result, ok := op.call(i, e, f, acc, e); ok {
+			acc = first(result)
+		}
+	}
+	return functor.commit(op, []interface{}{acc})
+}
+
+// GroupBy (mode: FunctionMode, key: func(e any): any) Сгруппировать элементы по ключу.
+// Коллекция становится списком TGroup в порядке первого появления ключей
+func (functor *Functor) GroupBy(mode FunctionMode, key dynamic.Lambda) *Functor {
+	if functor.done() {
+		return functor
+	}
+	op := functor.operation("GroupBy")
+	groups := make([]*TGroup, 0)
+	index := make(map[interface{}]*TGroup)
+	results, ok := op.each(mode, functor.collection, key)
+	for i, result := range results {
+		if !ok[i] {
+			continue
+		}
+		k := first(result)
+		g, found := index[hashable(k)]
+		if !found {
+			g = &TGroup{Key: k}
+			index[hashable(k)] = g
+			groups = append(groups, g)
+		}
+		g.Items = append(g.Items, functor.collection[i])
+	}
+	collection := make([]interface{}, 0, len(groups))
+	for _, g := range groups {
+		collection = append(collection, g)
+	}
+	return functor.commit(op, collection)
+}
+
+// SortBy (mode: FunctionMode, key: func(e any): any) Устойчиво отсортировать элементы по возрастанию ключа.
+// Числа сравниваются как числа, строки - как строки, остальное - по текстовому виду.
+// При Skip элементы без ключа удаляются
+func (functor *Functor) SortBy(mode FunctionMode, key dynamic.Lambda) *Functor {
+	if functor.done() {
+		return functor
+	}
+	op := functor.operation("SortBy")
+	keys, ok := op.each(mode, functor.collection, key)
+	order := make([]int, 0, len(functor.collection))
+	for i := range functor.collection {
+		if ok[i] {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return compare(first(keys[order[i]]), first(keys[order[j]])) < 0
+	})
+	collection := make([]interface{}, len(order))
+	for i, k := range order {
+		collection[i] = functor.collection[k]
+	}
+	return functor.commit(op, collection)
+}
+
+// Distinct (mode: FunctionMode, key: ...Lambda) Оставить первые элементы с различными значениями
+// или, если задана лямбда, с различными ключами
+func (functor *Functor) Distinct(mode FunctionMode, key ...dynamic.Lambda) *Functor {
+	if functor.done() {
+		return functor
+	}
+	op := functor.operation("Distinct")
+	var (
+		keys [][]interface{}
+		ok   []bool
+	)
+	if len(key) > 0 {
+		keys, ok = op.each(mode, functor.collection, key[0])
+	}
+	seen := make(map[interface{}]bool)
+	collection := make([]interface{}, 0, len(functor.collection))
+	for i, e := range functor.collection {
+		k := e
+		if keys != nil {
+			if !ok[i] {
+				continue
+			}
+			k = first(keys[i])
+		}
+		if seen[hashable(k)] {
+			continue
+		}
+		seen[hashable(k)] = true
+		collection = append(collection, e)
+	}
+	return functor.commit(op, collection)
+}
+
+// Take (n: int) Оставить первые n элементов
+func (functor *Functor) Take(n int) *Functor {
+	if functor.done() {
+		return functor
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n > len(functor.collection) {
+		n = len(functor.collection)
+	}
+	return functor.commit(functor.operation(fmt.Sprintf("Take(%d)", n)), append([]interface{}{}, functor.collection[:n]...))
+}
+
+// Skip (n: int) Пропустить первые n элементов
+func (functor *Functor) Skip(n int) *Functor {
+	if functor.done() {
+		return functor
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n > len(functor.collection) {
+		n = len(functor.collection)
+	}
+	return functor.commit(functor.operation(fmt.Sprintf("Skip(%d)", n)), append([]interface{}{}, functor.collection[n:]...))
+}
+
+// Zip (others: ...[]any) Соединить элементы с элементами других коллекций по индексу в TTuple.
+// Длина результата равна длине самой короткой коллекции
+func (functor *Functor) Zip(others ...[]interface{}) *Functor {
+	if functor.done() {
+		return functor
+	}
+	op := functor.operation("Zip")
+	n := len(functor.collection)
+	for _, other := range others {
+		if len(other) < n {
+			n = len(other)
+		}
+	}
+	collection := make([]interface{}, n)
+	for i := range collection {
+		t := TTuple{functor.collection[i]}
+		for _, other := range others {
+			t = append(t, other[i])
+		}
+		collection[i] = t
+	}
+	return functor.commit(op, collection)
+}
+
+// Chunk (size: int) Разбить коллекцию на TTuple по size элементов; последний может быть короче
+func (functor *Functor) Chunk(size int) *Functor {
+	if functor.done() {
+		return functor
+	}
+	if size <= 0 {
+		size = 1
+	}
+	op := functor.operation(fmt.Sprintf("Chunk(%d)", size))
+	collection := make([]interface{}, 0, (len(functor.collection)+size-1)/size)
+	for i := 0; i < len(functor.collection); i += size {
+		j := i + size
+		if j > len(functor.collection) {
+			j = len(functor.collection)
+		}
+		collection = append(collection, append(TTuple{}, functor.collection[i:j]...))
+	}
+	return functor.commit(op, collection)
+}
+
+// Partition (mode: FunctionMode, f: func(e any): bool) Разделить коллекцию на два TTuple:
+// элементы, для которых условие истинно, и остальные. При Skip элементы с ошибкой не попадают никуда
+func (functor *Functor) Partition(mode FunctionMode, f dynamic.Lambda) *Functor {
+	if functor.done() {
+		return functor
+	}
+	op := functor.operation("Partition")
+	yes, no := TTuple{}, TTuple{}
+	match, ok := op.tests(mode, functor.collection, f)
+	for i, e := range functor.collection {
+		switch {
+		case !ok[i]:
+		case match[i]:
+			yes = append(yes, e)
+		default:
+			no = append(no, e)
+		}
+	}
+	return functor.commit(op, []interface{}{yes, no})
+}
+
+// Find (mode: FunctionMode, f: func(e any): bool) Первый элемент, для которого условие истинно
+func (functor *Functor) Find(mode FunctionMode, f dynamic.Lambda) (interface{}, bool) {
+	if functor.done() {
+		return nil, false
+	}
+	op := functor.operation("Find")
+	defer functor.finish(op)
+	if mode == Sync {
+		for i, e := range functor.collection {
+			if match, _ := op.test(i, e, f); match {
+				return e, true
+			}
+		}
+		return nil, false
+	}
+	match, _ := op.tests(mode, functor.collection, f)
+	for i, e := range functor.collection {
+		if match[i] && !op.stopped() {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// Any (mode: FunctionMode, f: func(e any): bool) Истинно ли условие хотя бы для одного элемента
+func (functor *Functor) Any(mode FunctionMode, f dynamic.Lambda) bool {
+	_, ok := functor.Find(mode, f)
+	return ok
+}
+
+// All (mode: FunctionMode, f: func(e any): bool) Истинно ли условие для всех элементов.
+// При Skip элементы с ошибкой не учитываются
+func (functor *Functor) All(mode FunctionMode, f dynamic.Lambda) bool {
+	if functor.done() {
+		return false
+	}
+	op := functor.operation("All")
+	match, ok := op.tests(mode, functor.collection, f)
+	for i := range functor.collection {
+		if ok[i] && !match[i] {
+			functor.finish(op)
+			return false
+		}
+	}
+	return functor.finish(op)
+}
+
+// Count (mode: FunctionMode, f: ...Lambda) Число элементов или, если задано условие, элементов, для которых оно истинно
+func (functor *Functor) Count(mode FunctionMode, f ...dynamic.Lambda) int {
+	if len(f) == 0 {
+		return len(functor.collection)
+	}
+	if functor.done() {
+		return 0
+	}
+	op := functor.operation("Count")
+	n := 0
+	match, _ := op.tests(mode, functor.collection, f[0])
+	for i := range match {
+		if match[i] {
+			n++
+		}
+	}
+	if !functor.finish(op) {
+		return 0
+	}
+	return n
+}
+
+// tKey Ключ карты для несравнимого значения; отдельный тип не совпадает с ключом-строкой
+type tKey string
+
+// hashable Ключ карты для значения: само значение или его каноническая запись с типами, если оно несравнимо.
+// Структуры и массивы с несравнимым значением в поле-интерфейсе сравнимы по типу, но паникуют
+// при хешировании, поэтому проверяются значения, а не только тип
+func hashable(v interface{}) interface{} {
+	if v == nil || comparable(reflect.ValueOf(v)) {
+		return v
+	}
+	var b strings.Builder
+	canonical(&b, reflect.ValueOf(v))
+	return tKey(b.String())
+}
+
+// canonical Записать значение с типом каждого вложенного значения, чтобы []interface{}{1} и
+// []interface{}{"1"} или {"a": 1.0} и {"a": "1"} давали разные ключи. Ключи карт упорядочены
+func canonical(b *strings.Builder, v reflect.Value) {
+	if !v.IsValid() {
+		b.WriteString("nil")
+		return
+	}
+	b.WriteString(v.Type().String())
+	switch v.Kind() {
+	case reflect.Interface:
+		b.WriteByte('(')
+		if !v.IsNil() {
+			canonical(b, v.Elem())
+		}
+		b.WriteByte(')')
+	case reflect.Ptr, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		fmt.Fprintf(b, "(%#x)", v.Pointer())
+	case reflect.String:
+		b.WriteString(strconv.Quote(v.String()))
+	case reflect.Slice, reflect.Array:
+		b.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			canonical(b, v.Index(i))
+			b.WriteByte(',')
+		}
+		b.WriteByte(']')
+	case reflect.Struct:
+		b.WriteByte('{')
+		for i := 0; i < v.NumField(); i++ {
+			canonical(b, v.Field(i))
+			b.WriteByte(',')
+		}
+		b.WriteByte('}')
+	case reflect.Map:
+		entries := make([]string, 0, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			var e strings.Builder
+			canonical(&e, it.Key())
+			e.WriteByte(':')
+			canonical(&e, it.Value())
+			entries = append(entries, e.String())
+		}
+		sort.Strings(entries)
+		b.WriteByte('{')
+		for _, e := range entries {
+			b.WriteString(e)
+			b.WriteByte(',')
+		}
+		b.WriteByte('}')
+	default:
+		fmt.Fprintf(b, "(%v)", v)
+	}
+}
+
+// comparable Можно ли сравнить значение и все вложенные в него значения интерфейсов
+func comparable(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface:
+		return v.IsNil() || comparable(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !comparable(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !comparable(v.Index(i)) {
+				return false
+			}
+		}
+		return v.Type().Comparable()
+	}
+	return v.Type().Comparable()
+}
+
+// compare Сравнить ключи сортировки
+func compare(a, b interface{}) int {
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	if x, ok := a.(string); ok {
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func number(v interface{}) (float64, bool) {
+	r := reflect.ValueOf(v)
+	switch {
+	case v == nil:
+		return 0, false
+	case r.Kind() >= reflect.Int && r.Kind() <= reflect.Int64:
+		return float64(r.Int()), true
+	case r.Kind() >= reflect.Uint && r.Kind() <= reflect.Uintptr:
+		return float64(r.Uint()), true
+	case r.Kind() == reflect.Float32 || r.Kind() == reflect.Float64:
+		return r.Float(), true
+	}
+	return 0, false
+}
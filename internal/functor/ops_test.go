@@ -0,0 +1,243 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package functor
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// root Коллекция функтора; ошибки операторов проваливают тест
+func root(t *testing.T, f *Functor) []interface{} {
+	t.Helper()
+	if errs := f.Errors(); len(errs) != 0 {
+		t.Fatal(TErrors(errs))
+	}
+	return f.Result().Root
+}
+
+func equal(t *testing.T, got, want []interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+}
+
+// tKeyed Сравнимый тип, значение которого может оказаться несравнимым
+type tKeyed struct {
+	Name  string
+	Value interface{}
+}
+
+func TestFlatMap(t *testing.T) {
+	spread := func(e interface{}) interface{} {
+		n := e.(int)
+		if n%2 == 0 {
+			return []interface{}{n, []interface{}{n}}
+		}
+		return TTuple{n, n}
+	}
+	for _, mode := range []FunctionMode{Sync, Async} {
+		equal(t, root(t, F(1, 2).FlatMap(mode, spread)), []interface{}{1, 1, 2, []interface{}{2}})
+	}
+}
+
+func TestReduceFold(t *testing.T) {
+	sum := func(acc, e interface{}) interface{} { return acc.(int) + e.(int) }
+	equal(t, root(t, F(1, 2, 3).Reduce(sum)), []interface{}{6})
+	equal(t, root(t, F().Reduce(sum)), nil)
+	equal(t, root(t, F(1, 2, 3).Fold(10, sum)), []interface{}{16})
+	equal(t, root(t, F().Fold(10, sum)), []interface{}{10})
+	concat := func(acc, e interface{}) interface{} { return acc.(string) + e.(string) }
+	equal(t, root(t, F("a", "b", "c").Reduce(concat)), []interface{}{"abc"})
+}
+
+// colliding Несравнимые значения, текстовый вид которых совпадает, и повтор первого из них
+func colliding() []interface{} {
+	return []interface{}{
+		[]interface{}{1},
+		[]interface{}{"1"},
+		[]interface{}{1.0},
+		map[string]interface{}{"a": 1.0},
+		map[string]interface{}{"a": "1"},
+		map[string]interface{}{"a": 1},
+		tKeyed{"a", []interface{}{nil}},
+		tKeyed{"a", []interface{}{"<nil>"}},
+		[]interface{}{1},
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	parity := func(e interface{}) interface{} { return e.(int) % 2 }
+	for _, mode := range []FunctionMode{Sync, Async} {
+		equal(t, root(t, F(3, 1, 2, 5, 4).GroupBy(mode, parity)), []interface{}{
+			&TGroup{Key: 1, Items: []interface{}{3, 1, 5}},
+			&TGroup{Key: 0, Items: []interface{}{2, 4}},
+		})
+	}
+	tags := func(e interface{}) interface{} { return e.(tKeyed).Value }
+	got := root(t, F(tKeyed{"a", []int{1}}, tKeyed{"b", []int{1}}, tKeyed{"c", []int{2}}).GroupBy(Sync, tags))
+	if len(got) != 2 || len(got[0].(*TGroup).Items) != 2 {
+		t.Fatalf("slice keys grouped as %#v", got)
+	}
+	values := colliding()
+	got = root(t, F(values...).GroupBy(Sync, func(e interface{}) interface{} { return e }))
+	if len(got) != len(values)-1 || len(got[0].(*TGroup).Items) != 2 {
+		t.Fatalf("colliding keys grouped as %#v", got)
+	}
+}
+
+func TestSortBy(t *testing.T) {
+	identity := func(e interface{}) interface{} { return e }
+	for _, mode := range []FunctionMode{Sync, Async} {
+		equal(t, root(t, F(3, 1.5, uint(2), -1).SortBy(mode, identity)), []interface{}{-1, 1.5, uint(2), 3})
+		equal(t, root(t, F("b", "c", "a").SortBy(mode, identity)), []interface{}{"a", "b", "c"})
+	}
+	length := func(e interface{}) interface{} { return len(e.(string)) }
+	equal(t, root(t, F("bb", "a", "cc", "d").SortBy(Sync, length)), []interface{}{"a", "d", "bb", "cc"})
+}
+
+func TestDistinct(t *testing.T) {
+	equal(t, root(t, F(1, 2, 1, "1", 2).Distinct(Sync)), []interface{}{1, 2, "1"})
+	length := func(e interface{}) interface{} { return len(e.(string)) }
+	equal(t, root(t, F("a", "bb", "c", "dd", "eee").Distinct(Async, length)), []interface{}{"a", "bb", "eee"})
+	equal(t, root(t, F([]int{1}, []int{1}, []int{2}).Distinct(Sync)), []interface{}{[]int{1}, []int{2}})
+	keyed := []interface{}{
+		tKeyed{"a", []int{1}},
+		tKeyed{"a", []int{1}},
+		tKeyed{"a", map[string]int{"x": 1}},
+		[1]interface{}{[]int{1}},
+		[1]interface{}{[]int{1}},
+		tKeyed{"a", 1},
+		tKeyed{"a", 1},
+	}
+	equal(t, root(t, F(keyed...).Distinct(Sync)), []interface{}{keyed[0], keyed[2], keyed[3], keyed[5]})
+	values := colliding()
+	equal(t, root(t, F(values...).Distinct(Sync)), values[:len(values)-1])
+}
+
+func TestHashable(t *testing.T) {
+	cases := []struct {
+		value interface{}
+		same  bool
+	}{
+		{nil, true},
+		{1, true},
+		{"a", true},
+		{tKeyed{"a", 1}, true},
+		{tKeyed{"a", nil}, true},
+		{[2]interface{}{1, "a"}, true},
+		{[]int{1}, false},
+		{map[string]int{}, false},
+		{tKeyed{"a", []int{1}}, false},
+		{tKeyed{"a", tKeyed{"b", []int{1}}}, false},
+		{[1]interface{}{[]int{1}}, false},
+		{map[int]string{2: "b", 1: "a", 3: "c"}, false},
+	}
+	for _, c := range cases {
+		key := hashable(c.value)
+		seen := map[interface{}]bool{key: true}
+		if same := reflect.DeepEqual(key, c.value); same != c.same || !seen[key] {
+			t.Errorf("hashable(%#v) = %#v", c.value, key)
+		}
+	}
+	keys := make(map[interface{}]interface{})
+	for _, v := range colliding()[:len(colliding())-1] {
+		if other, found := keys[hashable(v)]; found {
+			t.Errorf("%#v and %#v have the same key %#v", v, other, hashable(v))
+		}
+		keys[hashable(v)] = v
+	}
+	if hashable([]interface{}{1}) != hashable([]interface{}{1}) {
+		t.Error("equal slices have different keys")
+	}
+	if hashable(map[int]string{2: "b", 1: "a"}) != hashable(map[int]string{1: "a", 2: "b"}) {
+		t.Error("map key depends on iteration order")
+	}
+	if k := hashable([]string{"a"}); k == hashable(fmt.Sprint(k)) {
+		t.Error("slice key equals a string")
+	}
+}
+
+func TestTakeSkip(t *testing.T) {
+	cases := []struct {
+		n          int
+		take, skip []interface{}
+	}{
+		{-1, []interface{}{}, []interface{}{1, 2, 3}},
+		{0, []interface{}{}, []interface{}{1, 2, 3}},
+		{2, []interface{}{1, 2}, []interface{}{3}},
+		{5, []interface{}{1, 2, 3}, []interface{}{}},
+	}
+	for _, c := range cases {
+		equal(t, root(t, F(1, 2, 3).Take(c.n)), c.take)
+		equal(t, root(t, F(1, 2, 3).Skip(c.n)), c.skip)
+	}
+}
+
+func TestZipChunk(t *testing.T) {
+	equal(t, root(t, F(1, 2, 3).Zip([]interface{}{"a", "b"}, []interface{}{true, false, true})), []interface{}{
+		TTuple{1, "a", true},
+		TTuple{2, "b", false},
+	})
+	equal(t, root(t, F(1, 2, 3, 4, 5).Chunk(2)), []interface{}{TTuple{1, 2}, TTuple{3, 4}, TTuple{5}})
+	equal(t, root(t, F(1, 2).Chunk(0)), []interface{}{TTuple{1}, TTuple{2}})
+	identity := func(e interface{}) interface{} { return e }
+	equal(t, root(t, F(1, 2).Chunk(2).Map(Sync, identity)), []interface{}{TTuple{1, 2}})
+}
+
+func TestPartition(t *testing.T) {
+	even := func(e interface{}) bool { return e.(int)%2 == 0 }
+	for _, mode := range []FunctionMode{Sync, Async} {
+		equal(t, root(t, F(1, 2, 3, 4).Partition(mode, even)), []interface{}{TTuple{2, 4}, TTuple{1, 3}})
+	}
+}
+
+func TestQueries(t *testing.T) {
+	even := func(e interface{}) bool { return e.(int)%2 == 0 }
+	big := func(e interface{}) bool { return e.(int) > 10 }
+	for _, mode := range []FunctionMode{Sync, Async} {
+		f := F(1, 3, 4, 6)
+		if e, ok := f.Find(mode, even); !ok || e != 4 {
+			t.Errorf("Find = %v, %v; want 4", e, ok)
+		}
+		if _, ok := f.Find(mode, big); ok {
+			t.Error("Find matched nothing but returned an element")
+		}
+		if !f.Any(mode, even) || f.Any(mode, big) {
+			t.Error("Any is wrong")
+		}
+		if f.All(mode, even) || !F(2, 4).All(mode, even) || !F().All(mode, even) {
+			t.Error("All is wrong")
+		}
+		if n := f.Count(mode, even); n != 2 {
+			t.Errorf("Count(even) = %d, want 2", n)
+		}
+		if n := f.Count(mode); n != 4 {
+			t.Errorf("Count() = %d, want 4", n)
+		}
+		equal(t, root(t, f), []interface{}{1, 3, 4, 6})
+	}
+}
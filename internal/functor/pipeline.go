@@ -0,0 +1,261 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package functor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/x-research-team/kernel/internal/dynamic"
+)
+
+// ErrPipeline Ошибка описания конвейера
+var ErrPipeline = errors.New("invalid pipeline")
+
+// TPipeline Конвейер функтора в JSON: шаги по порядку, лямбды - функции реестра по имени
+type TPipeline struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parallel    int             `json:"parallel,omitempty"`    // Число горутин для шагов async
+	OnError     string          `json:"on_error,omitempty"`    // skip (по умолчанию), fail_fast или replace
+	Replacement interface{}     `json:"replacement,omitempty"` // Значение для replace
+	Steps       []TPipelineStep `json:"steps"`
+}
+
+// TPipelineStep Шаг конвейера: оператор функтора и его параметры
+type TPipelineStep struct {
+	Operator string            `json:"operator"`
+	Function string            `json:"function,omitempty"` // Имя функции реестра
+	Mode     string            `json:"mode,omitempty"`     // sync (по умолчанию) или async
+	N        int               `json:"n,omitempty"`        // Число элементов для take, skip и chunk
+	Init     interface{}       `json:"init,omitempty"`     // Начальное значение fold
+	Name     string            `json:"name,omitempty"`     // Имя контрольной точки checkpoint
+	Branches [][]TPipelineStep `json:"branches,omitempty"` // Шаги веток branch, race и first
+}
+
+// TPipelineError Ошибки описания конвейера
+type TPipelineError struct {
+	Pipeline string
+	Problems []string
+}
+
+func (e *TPipelineError) Error() string {
+	return fmt.Sprintf("[Pipeline] %v: %v: %v", e.Pipeline, ErrPipeline, strings.Join(e.Problems, "; "))
+}
+
+func (e *TPipelineError) Unwrap() error {
+	return ErrPipeline
+}
+
+var (
+	policies = map[string]TErrorPolicy{"": Skip, "skip": Skip, "fail_fast": FailFast, "replace": Replace}
+	modes    = map[string]FunctionMode{"": Sync, "sync": Sync, "async": Async}
+)
+
+// tCompiler Сборка шагов конвейера
+type tCompiler struct {
+	registry *dynamic.TRegistry
+	problems []string
+}
+
+// Compile (registry: *TRegistry) Проверить конвейер и собрать его в функцию над функтором.
+// Все найденные ошибки описания возвращаются одной TPipelineError
+func (p *TPipeline) Compile(registry *dynamic.TRegistry) (func(*Functor) *Functor, error) {
+	c := &tCompiler{registry: registry}
+	policy, ok := policies[p.OnError]
+	if !ok {
+		c.problem("on_error", "unknown policy %q", p.OnError)
+	}
+	if p.Parallel < 0 {
+		c.problem("parallel", "must not be negative")
+	}
+	steps := c.steps("steps", p.Steps)
+	if len(c.problems) > 0 {
+		return nil, &TPipelineError{Pipeline: p.Name, Problems: c.problems}
+	}
+	return func(functor *Functor) *Functor {
+		if p.Parallel > 0 {
+			functor.Parallel(p.Parallel)
+		}
+		return steps(functor.OnError(policy, p.Replacement))
+	}, nil
+}
+
+// Functions Имена функций реестра, на которые ссылаются шаги конвейера и его веток, по порядку
+func (p *TPipeline) Functions() []string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	var walk func(steps []TPipelineStep)
+	walk = func(steps []TPipelineStep) {
+		for _, step := range steps {
+			if step.Function != "" && !seen[step.Function] {
+				seen[step.Function] = true
+				names = append(names, step.Function)
+			}
+			for _, branch := range step.Branches {
+				walk(branch)
+			}
+		}
+	}
+	walk(p.Steps)
+	return names
+}
+
+// Run (ctx: context.Context, registry: *TRegistry, args: ...any) Выполнить конвейер над элементами args
+func (p *TPipeline) Run(ctx context.Context, registry *dynamic.TRegistry, args ...interface{}) (*Functor, error) {
+	pipeline, err := p.Compile(registry)
+	if err != nil {
+		return nil, err
+	}
+	return pipeline(F(args...).WithContext(ctx)), nil
+}
+
+func (c *tCompiler) problem(at, format string, args ...interface{}) {
+	c.problems = append(c.problems, fmt.Sprintf("%v: %v", at, fmt.Sprintf(format, args...)))
+}
+
+// steps Собрать шаги по порядку
+func (c *tCompiler) steps(at string, steps []TPipelineStep) func(*Functor) *Functor {
+	compiled := make([]func(*Functor) *Functor, 0, len(steps))
+	for i, step := range steps {
+		if f := c.step(fmt.Sprintf("%v[%d]", at, i), step); f != nil {
+			compiled = append(compiled, f)
+		}
+	}
+	return func(functor *Functor) *Functor {
+		for _, f := range compiled {
+			functor = f(functor)
+		}
+		return functor
+	}
+}
+
+// step Собрать шаг; при ошибке описания шаг пропускается, а ошибка запоминается
+func (c *tCompiler) step(at string, step TPipelineStep) func(*Functor) *Functor {
+	mode, ok := modes[step.Mode]
+	if !ok {
+		c.problem(at, "unknown mode %q", step.Mode)
+	}
+	var f dynamic.Lambda
+	if step.Function != "" {
+		if fn, ok := c.registry.Lookup(step.Function); ok {
+			f = fn.Func()
+		} else {
+			c.problem(at, "function %q is not registered", step.Function)
+		}
+	}
+	// function Функция обязательна (required) или запрещена для оператора
+	function := func(required bool) {
+		switch {
+		case required && step.Function == "":
+			c.problem(at, "%v requires a function", step.Operator)
+		case !required && step.Function != "":
+			c.problem(at, "%v does not take a function", step.Operator)
+		}
+	}
+	count := func(min int) {
+		if step.N < min {
+			c.problem(at, "%v requires n >= %d", step.Operator, min)
+		}
+	}
+	switch step.Operator {
+	case "map":
+		function(true)
+		return func(functor *Functor) *Functor { return functor.Map(mode, f) }
+	case "filter":
+		function(true)
+		return func(functor *Functor) *Functor { return functor.Filter(mode, f) }
+	case "flat_map":
+		function(true)
+		return func(functor *Functor) *Functor { return functor.FlatMap(mode, f) }
+	case "apply":
+		function(true)
+		return func(functor *Functor) *Functor { return functor.Apply(mode, f) }
+	case "reduce":
+		function(true)
+		return func(functor *Functor) *Functor { return functor.Reduce(f) }
+	case "fold":
+		function(true)
+		return func(functor *Functor) *Functor { return functor.Fold(step.Init, f) }
+	case "group_by":
+		function(true)
+		return func(functor *Functor) *Functor { return functor.GroupBy(mode, f) }
+	case "sort_by":
+		function(true)
+		return func(functor *Functor) *Functor { return functor.SortBy(mode, f) }
+	case "partition":
+		function(true)
+		return func(functor *Functor) *Functor { return functor.Partition(mode, f) }
+	case "distinct":
+		if step.Function == "" {
+			return func(functor *Functor) *Functor { return functor.Distinct(mode) }
+		}
+		return func(functor *Functor) *Functor { return functor.Distinct(mode, f) }
+	case "take":
+		function(false)
+		count(0)
+		return func(functor *Functor) *Functor { return functor.Take(step.N) }
+	case "skip":
+		function(false)
+		count(0)
+		return func(functor *Functor) *Functor { return functor.Skip(step.N) }
+	case "chunk":
+		function(false)
+		count(1)
+		return func(functor *Functor) *Functor { return functor.Chunk(step.N) }
+	case "checkpoint":
+		function(false)
+		if step.Name == "" {
+			c.problem(at, "checkpoint requires a name")
+		}
+		return func(functor *Functor) *Functor { return functor.Checkpoint(step.Name) }
+	case "branch", "race", "first":
+		function(false)
+		if len(step.Branches) == 0 {
+			c.problem(at, "%v requires branches", step.Operator)
+		}
+		branches := make([]func(*Functor) *Functor, 0, len(step.Branches))
+		for i, steps := range step.Branches {
+			branches = append(branches, c.steps(fmt.Sprintf("%v.branches[%d]", at, i), steps))
+		}
+		switch step.Operator {
+		case "race":
+			return func(functor *Functor) *Functor { return functor.Race(branches...) }
+		case "first":
+			return func(functor *Functor) *Functor { return functor.First(branches...) }
+		}
+		return func(functor *Functor) *Functor { return functor.Fork(mode, branches...) }
+	case "merge":
+		function(false)
+		return func(functor *Functor) *Functor { return functor.Merge() }
+	case "join_by":
+		function(true)
+		return func(functor *Functor) *Functor { return functor.JoinBy(mode, f) }
+	default:
+		c.problem(at, "unknown operator %q", step.Operator)
+		return nil
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package functor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/x-research-team/kernel/internal/dynamic"
+)
+
+// registry Реестр с функциями примеров конвейеров
+func registry(t *testing.T) *dynamic.TRegistry {
+	t.Helper()
+	r := dynamic.Registry()
+	for name, f := range map[string]dynamic.Lambda{
+		"positive": func(x float64) bool { return x > 0 },
+		"double":   func(x float64) float64 { return x * 2 },
+		"sum":      func(acc, x float64) float64 { return acc + x },
+		"invert": func(x float64) (float64, error) {
+			if x == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return 1 / x, nil
+		},
+	} {
+		if err := r.Register(name, "", f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+func pipeline(t *testing.T, definition string) *TPipeline {
+	t.Helper()
+	p := &TPipeline{Name: "test"}
+	if err := json.Unmarshal([]byte(definition), p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestCompileErrors(t *testing.T) {
+	p := pipeline(t, `{
+		"on_error": "ignore",
+		"parallel": -1,
+		"steps": [
+			{"operator": "map"},
+			{"operator": "map", "function": "missing"},
+			{"operator": "filter", "function": "positive", "mode": "eventually"},
+			{"operator": "take", "function": "double", "n": -1},
+			{"operator": "chunk"},
+			{"operator": "checkpoint"},
+			{"operator": "branch"},
+			{"operator": "first", "branches": [[{"operator": "explode"}]]},
+			{"operator": "explode"}
+		]
+	}`)
+	_, err := p.Compile(registry(t))
+	var invalid *TPipelineError
+	if !errors.As(err, &invalid) || !errors.Is(err, ErrPipeline) {
+		t.Fatalf("Compile error %v", err)
+	}
+	want := []string{
+		`on_error: unknown policy "ignore"`,
+		"parallel: must not be negative",
+		"steps[0]: map requires a function",
+		`steps[1]: function "missing" is not registered`,
+		`steps[2]: unknown mode "eventually"`,
+		"steps[3]: take does not take a function",
+		"steps[3]: take requires n >= 0",
+		"steps[4]: chunk requires n >= 1",
+		"steps[5]: checkpoint requires a name",
+		"steps[6]: branch requires branches",
+		`steps[7].branches[0][0]: unknown operator "explode"`,
+		`steps[8]: unknown operator "explode"`,
+	}
+	if !reflect.DeepEqual(invalid.Problems, want) {
+		t.Fatalf("problems\n%v\nwant\n%v", strings.Join(invalid.Problems, "\n"), strings.Join(want, "\n"))
+	}
+	if _, err := p.Run(context.Background(), registry(t), 1); !errors.Is(err, ErrPipeline) {
+		t.Fatalf("Run of an invalid pipeline = %v", err)
+	}
+}
+
+func TestPipelineRun(t *testing.T) {
+	p := pipeline(t, `{
+		"parallel": 2,
+		"on_error": "replace",
+		"replacement": 0,
+		"steps": [
+			{"operator": "filter", "function": "positive"},
+			{"operator": "map", "function": "double", "mode": "async"},
+			{"operator": "checkpoint", "name": "doubled"},
+			{"operator": "branch", "branches": [
+				[{"operator": "fold", "function": "sum", "init": 0}],
+				[{"operator": "take", "n": 2}, {"operator": "map", "function": "invert"}]
+			]}
+		]
+	}`)
+	if got := p.Functions(); !reflect.DeepEqual(got, []string{"positive", "double", "sum", "invert"}) {
+		t.Fatalf("Functions = %v", got)
+	}
+	f, err := p.Run(context.Background(), registry(t), -1.0, 2.0, 0.5, 3.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := f.Result()
+	equal(t, result.Root, []interface{}{4.0, 1.0, 6.0})
+	if len(result.Branches) != 2 {
+		t.Fatalf("%d branches, want 2", len(result.Branches))
+	}
+	equal(t, result.Branches[0].(*FunctorResult).Root, []interface{}{11.0})
+	equal(t, result.Branches[1].(*FunctorResult).Root, []interface{}{0.25, 1.0})
+	if len(f.Errors()) != 0 {
+		t.Fatal(TErrors(f.Errors()))
+	}
+
+	failing := pipeline(t, `{"steps": [{"operator": "map", "function": "invert"}]}`)
+	f, err = failing.Run(context.Background(), registry(t), 0.0, 2.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, f.Result().Root, []interface{}{0.5})
+	if errs := f.Errors(); len(errs) != 1 || errs[0].Value != 0.0 {
+		t.Fatalf("errors %v", TErrors(errs))
+	}
+}
@@ -0,0 +1,146 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package functor
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime/metrics"
+	"strings"
+	"time"
+)
+
+// TStage Статистика оператора функтора
+type TStage struct {
+	Operator string        `json:"operator"`
+	In       int           `json:"in"`               // Число элементов до оператора
+	Out      int           `json:"out"`              // Число элементов после; равно In, если коллекция не изменилась
+	Errors   int           `json:"errors,omitempty"` // Число ошибок элементов
+	Duration time.Duration `json:"-"`
+	Allocs   uint64        `json:"allocs,omitempty"` // Выделения памяти процессом за время оператора (см. Profile)
+	Bytes    uint64        `json:"bytes,omitempty"`
+	Branches [][]*TStage   `json:"branches,omitempty"` // Операторы веток Fork, Race и First
+}
+
+// MarshalJSON Статистика с длительностью текстом
+func (s *TStage) MarshalJSON() ([]byte, error) {
+	type stage TStage
+	return json.Marshal(&struct {
+		*stage
+		Duration string `json:"duration"`
+	}{(*stage)(s), s.Duration.String()})
+}
+
+func (s *TStage) String() string {
+	line := fmt.Sprintf("%v in=%d out=%d time=%v", s.Operator, s.In, s.Out, s.Duration)
+	if s.Errors != 0 {
+		line += fmt.Sprintf(" errors=%d", s.Errors)
+	}
+	if s.Allocs != 0 {
+		line += fmt.Sprintf(" allocs=%d bytes=%d", s.Allocs, s.Bytes)
+	}
+	return line
+}
+
+// tMemory Выделения памяти процессом с его запуска
+type tMemory struct {
+	allocs uint64
+	bytes  uint64
+}
+
+// allocated Прочитать счетчики выделений из runtime/metrics; в отличие от runtime.ReadMemStats
+// чтение не останавливает программу
+func allocated() *tMemory {
+	samples := []metrics.Sample{{Name: "/gc/heap/allocs:objects"}, {Name: "/gc/heap/allocs:bytes"}}
+	metrics.Read(samples)
+	m := new(tMemory)
+	if samples[0].Value.Kind() == metrics.KindUint64 {
+		m.allocs = samples[0].Value.Uint64()
+	}
+	if samples[1].Value.Kind() == metrics.KindUint64 {
+		m.bytes = samples[1].Value.Uint64()
+	}
+	return m
+}
+
+// Profile (enabled: bool) Записывать статистику операторов (Stages, Explain, Result) с выделениями памяти.
+// Без профилирования статистика не хранится. Выделения считаются по всему процессу, поэтому
+// при параллельной работе они приблизительны
+func (functor *Functor) Profile(enabled bool) *Functor {
+	functor.profile = enabled
+	return functor
+}
+
+// Stages Статистика операторов функтора по порядку; пусто, если Profile не включен
+func (functor *Functor) Stages() []*TStage {
+	return append([]*TStage{}, functor.stages...)
+}
+
+// measure Завершить статистику оператора и сохранить ее, если включен Profile
+func (functor *Functor) measure(op *tOperation) {
+	if !functor.profile {
+		return
+	}
+	op.stage.Duration = time.Since(op.start)
+	op.stage.Out = len(functor.collection)
+	op.stage.Errors = len(op.errors)
+	if op.memory != nil {
+		memory := allocated()
+		op.stage.Allocs = memory.allocs - op.memory.allocs
+		op.stage.Bytes = memory.bytes - op.memory.bytes
+	}
+	functor.stages = append(functor.stages, op.stage)
+}
+
+// Explain Дерево выполненных операторов с ветками и их статистикой. Операторы записываются
+// только при включенном Profile; без него остается строка самого функтора
+func (functor *Functor) Explain() string {
+	b := new(strings.Builder)
+	fmt.Fprintf(b, "F in=%d out=%d\n", functor.stageIn(), len(functor.collection))
+	explain(b, functor.stages, "")
+	return b.String()
+}
+
+// stageIn Число элементов, с которыми функтор начал работу
+func (functor *Functor) stageIn() int {
+	if len(functor.stages) == 0 {
+		return len(functor.collection)
+	}
+	return functor.stages[0].In
+}
+
+// explain Записать операторы с отступом indent; ветки - ниже оператора, создавшего их
+func explain(b *strings.Builder, stages []*TStage, indent string) {
+	for i, stage := range stages {
+		prefix, next := "├─ ", "│  "
+		if i == len(stages)-1 {
+			prefix, next = "└─ ", "   "
+		}
+		fmt.Fprintf(b, "%v%v%v\n", indent, prefix, stage)
+		for j, branch := range stage.Branches {
+			fmt.Fprintf(b, "%v%vbranch %d\n", indent, next, j)
+			explain(b, branch, indent+next+"   ")
+		}
+	}
+}
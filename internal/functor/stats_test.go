@@ -0,0 +1,89 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package functor
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// explained Дерево Explain без длительностей и счетчиков памяти, которые меняются от запуска к запуску
+func explained(f *Functor) string {
+	return regexp.MustCompile(` (time=\S+|allocs=\d+ bytes=\d+)`).ReplaceAllString(f.Explain(), "")
+}
+
+func TestProfileOff(t *testing.T) {
+	f := F(1, 2, 3).Map(Sync, double).Branch(func(b *Functor) *Functor { return b.Take(1) }).Merge()
+	if len(f.Stages()) != 0 || f.Result().Stages != nil {
+		t.Fatalf("stages recorded without Profile: %v", f.Stages())
+	}
+	if got := f.Explain(); got != "F in=1 out=1\n" {
+		t.Fatalf("Explain = %q", got)
+	}
+	if strings.Contains(string(f.Result().ToJson()), "Stages") {
+		t.Fatal("Result has stages without Profile")
+	}
+}
+
+func TestProfile(t *testing.T) {
+	// Большие строки: мелкие выделения runtime/metrics учитывает с запаздыванием, по спанам
+	text := func(e interface{}) interface{} { return strings.Repeat(fmt.Sprint(e), 1<<16) }
+	f := F(1, 2, 3, 4).Profile(true).Map(Sync, text).Fork(Sync,
+		func(b *Functor) *Functor { return b.Map(Sync, half).Take(2) },
+		func(b *Functor) *Functor { return b.Skip(1) },
+	).Merge()
+	want := `F in=4 out=3
+├─ Map in=4 out=4
+├─ Fork(2) in=4 out=4
+│  branch 0
+│     ├─ Map in=4 out=0 errors=4
+│     └─ Take(0) in=0 out=0
+│  branch 1
+│     └─ Skip(1) in=4 out=3
+└─ Merge in=4 out=3
+`
+	if got := explained(f); got != want {
+		t.Fatalf("Explain\n%v\nwant\n%v", got, want)
+	}
+	stages := f.Stages()
+	if len(stages) != 3 || stages[0].Allocs == 0 || stages[0].Bytes < 4<<16 || stages[0].Duration <= 0 {
+		t.Fatalf("Map stage %+v", stages[0])
+	}
+	if len(stages[1].Branches) != 2 || len(stages[1].Branches[0]) != 2 {
+		t.Fatalf("Fork branches %+v", stages[1].Branches)
+	}
+	if len(f.Result().Stages) != 3 {
+		t.Fatal("Result has no stages with Profile")
+	}
+	buffer, err := json.Marshal(stages[1].Branches[0][0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^\{"operator":"Map","in":4,"out":0,"errors":4,("allocs":\d+,"bytes":\d+,)?"duration":"[^"]+"\}$`).Match(buffer) {
+		t.Fatalf("JSON %s", buffer)
+	}
+}
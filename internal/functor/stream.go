@@ -0,0 +1,388 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package functor
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/x-research-team/kernel/internal/dynamic"
+)
+
+// tStage Стадия потока: читает элементы из in и пишет результаты в out до закрытия in или остановки потока
+type tStage func(flow *tFlow, in <-chan interface{}, out chan<- interface{})
+
+// Stream Ленивый поток элементов. Операторы только добавляют стадии; элементы по одному
+// проходят через каналы без буфера, когда их запрашивает завершающая операция
+// (Collect, ForEach, Sink), поэтому медленный потребитель сдерживает источник
+type Stream struct {
+	ctx         context.Context
+	source      func(ctx context.Context, out chan<- interface{})
+	stages      []tStage
+	policy      TErrorPolicy
+	replacement interface{}
+}
+
+// tFlow Один запуск потока: контекст стадий и ошибки их элементов
+type tFlow struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	policy      TErrorPolicy
+	replacement interface{}
+	m           sync.Mutex
+	errors      []*TElementError
+}
+
+// S (args: ...any) Поток из значений
+func S(args ...interface{}) *Stream {
+	return &Stream{ctx: context.Background(), source: func(ctx context.Context, out chan<- interface{}) {
+		for _, e := range args {
+			if !send(ctx, out, e) {
+				return
+			}
+		}
+	}}
+}
+
+// From (ch: <-chan any) Поток из канала; поток заканчивается, когда канал закрыт
+func From(ch <-chan interface{}) *Stream {
+	return &Stream{ctx: context.Background(), source: func(ctx context.Context, out chan<- interface{}) {
+		for {
+			select {
+			case e, ok := <-ch:
+				if !ok || !send(ctx, out, e) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}}
+}
+
+// Generate (next: func(): (any, bool)) Поток значений next, пока она возвращает true; может быть бесконечным
+func Generate(next func() (interface{}, bool)) *Stream {
+	return &Stream{ctx: context.Background(), source: func(ctx context.Context, out chan<- interface{}) {
+		for {
+			e, ok := next()
+			if !ok || !send(ctx, out, e) {
+				return
+			}
+		}
+	}}
+}
+
+// Stream Поток элементов коллекции функтора с его контекстом и политикой ошибок
+func (functor *Functor) Stream() *Stream {
+	return S(functor.collection...).WithContext(functor.ctx).OnError(functor.policy, functor.replacement)
+}
+
+// WithContext (ctx: context.Context) Остановить поток при отмене ctx; завершающая операция вернет ctx.Err()
+func (s *Stream) WithContext(ctx context.Context) *Stream {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s.ctx = ctx
+	return s
+}
+
+// OnError (policy: TErrorPolicy, value: ...any) Поведение стадий при ошибке элемента (см. Functor.OnError).
+// FailFast останавливает поток; ошибки возвращает завершающая операция как TErrors
+func (s *Stream) OnError(policy TErrorPolicy, value ...interface{}) *Stream {
+	s.policy = policy
+	s.replacement = nil
+	if len(value) > 0 {
+		s.replacement = value[0]
+	}
+	return s
+}
+
+func (s *Stream) pipe(stage tStage) *Stream {
+	s.stages = append(s.stages, stage)
+	return s
+}
+
+// Map (lambdas: ...Lambda) Заменить каждый элемент результатом лямбды
+func (s *Stream) Map(lambdas ...dynamic.Lambda) *Stream {
+	for _, f := range lambdas {
+		f := f
+		s.pipe(func(flow *tFlow, in <-chan interface{}, out chan<- interface{}) {
+			i := 0
+			for e := range in {
+				result, ok := flow.call("Map", i, f, e)
+				if i++; ok && !send(flow.ctx, out, first(result)) {
+					return
+				}
+			}
+		})
+	}
+	return s
+}
+
+// Filter (lambdas: ...Lambda) Пропускать элементы, для которых условия истинны
+func (s *Stream) Filter(lambdas ...dynamic.Lambda) *Stream {
+	for _, f := range lambdas {
+		f := f
+		s.pipe(func(flow *tFlow, in <-chan interface{}, out chan<- interface{}) {
+			i := 0
+			for e := range in {
+				match := flow.test("Filter", i, f, e)
+				if i++; match && !send(flow.ctx, out, e) {
+					return
+				}
+			}
+		})
+	}
+	return s
+}
+
+// FlatMap (f: Lambda) Заменить каждый элемент элементами коллекции, которую вернула лямбда
+func (s *Stream) FlatMap(f dynamic.Lambda) *Stream {
+	return s.pipe(func(flow *tFlow, in <-chan interface{}, out chan<- interface{}) {
+		i := 0
+		for e := range in {
+			result, ok := flow.call("FlatMap", i, f, e)
+			if i++; !ok {
+				continue
+			}
+			var items []interface{}
+			switch v := first(result).(type) {
+			case []interface{}:
+				items = v
+			case TTuple:
+				items = v
+			default:
+				items = []interface{}{v}
+			}
+			for _, item := range items {
+				if !send(flow.ctx, out, item) {
+					return
+				}
+			}
+		}
+	})
+}
+
+// Take (n: int) Первые n элементов; после них источник останавливается
+func (s *Stream) Take(n int) *Stream {
+	return s.pipe(func(flow *tFlow, in <-chan interface{}, out chan<- interface{}) {
+		if n <= 0 {
+			return
+		}
+		i := 0
+		for e := range in {
+			if !send(flow.ctx, out, e) {
+				return
+			}
+			if i++; i >= n {
+				return
+			}
+		}
+	})
+}
+
+// Skip (n: int) Пропустить первые n элементов
+func (s *Stream) Skip(n int) *Stream {
+	return s.pipe(func(flow *tFlow, in <-chan interface{}, out chan<- interface{}) {
+		i := 0
+		for e := range in {
+			if i++; i <= n {
+				continue
+			}
+			if !send(flow.ctx, out, e) {
+				return
+			}
+		}
+	})
+}
+
+// Chunk (size: int) Собирать элементы в TTuple по size; последний может быть короче
+func (s *Stream) Chunk(size int) *Stream {
+	if size <= 0 {
+		size = 1
+	}
+	return s.pipe(func(flow *tFlow, in <-chan interface{}, out chan<- interface{}) {
+		chunk := make(TTuple, 0, size)
+		for e := range in {
+			if chunk = append(chunk, e); len(chunk) == size {
+				if !send(flow.ctx, out, chunk) {
+					return
+				}
+				chunk = make(TTuple, 0, size)
+			}
+		}
+		if len(chunk) > 0 {
+			send(flow.ctx, out, chunk)
+		}
+	})
+}
+
+// Distinct (key: ...Lambda) Пропускать только первые элементы с различными значениями или ключами.
+// Поток запоминает все увиденные ключи
+func (s *Stream) Distinct(key ...dynamic.Lambda) *Stream {
+	return s.pipe(func(flow *tFlow, in <-chan interface{}, out chan<- interface{}) {
+		seen := make(map[interface{}]bool)
+		i := 0
+		for e := range in {
+			k := e
+			if len(key) > 0 {
+				result, ok := flow.call("Distinct", i, key[0], e)
+				if i++; !ok {
+					continue
+				}
+				k = first(result)
+			}
+			if seen[hashable(k)] {
+				continue
+			}
+			seen[hashable(k)] = true
+			if !send(flow.ctx, out, e) {
+				return
+			}
+		}
+	})
+}
+
+// run Запустить источник и стадии; f получает выход последней стадии.
+// Когда f возвращается, источник и стадии останавливаются, и run дожидается их.
+// Ошибка - ctx.Err() отмененного контекста потока или ошибки элементов как TErrors
+func (s *Stream) run(f func(flow *tFlow, out <-chan interface{})) error {
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(s.ctx)
+	flow := &tFlow{ctx: ctx, cancel: cancel, policy: s.policy, replacement: s.replacement}
+	ch := make(chan interface{})
+	wg.Add(1 + len(s.stages))
+	go func() {
+		defer wg.Done()
+		defer close(ch)
+		s.source(ctx, ch)
+	}()
+	var out <-chan interface{} = ch
+	for _, stage := range s.stages {
+		in, next := out, make(chan interface{})
+		go func(stage tStage) {
+			defer wg.Done()
+			defer close(next)
+			stage(flow, in, next)
+		}(stage)
+		out = next
+	}
+	f(flow, out)
+	cancel()
+	wg.Wait()
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if len(flow.errors) != 0 {
+		return TErrors(flow.errors)
+	}
+	return nil
+}
+
+// call Вызвать лямбду стадии operator для элемента index. false - результата нет:
+// элемент пропущен, поток остановлен FailFast или отменой контекста
+func (flow *tFlow) call(operator string, index int, f dynamic.Lambda, e interface{}) ([]interface{}, bool) {
+	result, err := invoke(flow.ctx, f, e)
+	if err == nil {
+		return result, true
+	}
+	return flow.fail(operator, index, e, err)
+}
+
+// test Вызвать лямбду-условие стадии operator для элемента index
+func (flow *tFlow) test(operator string, index int, f dynamic.Lambda, e interface{}) bool {
+	match, err := condition(invoke(flow.ctx, f, e))
+	if err == nil {
+		return match
+	}
+	result, _ := flow.fail(operator, index, e, err)
+	return truth(result)
+}
+
+// fail Запомнить ошибку элемента и применить политику потока; отмена не запоминается
+func (flow *tFlow) fail(operator string, index int, value interface{}, err error) ([]interface{}, bool) {
+	if dynamic.Cancelled(err) {
+		return nil, false
+	}
+	flow.m.Lock()
+	flow.errors = append(flow.errors, &TElementError{Operator: operator, Index: index, Value: value, Panic: errors.Is(err, dynamic.ErrPanic), Err: err})
+	flow.m.Unlock()
+	switch flow.policy {
+	case Replace:
+		return []interface{}{flow.replacement}, true
+	case FailFast:
+		flow.cancel()
+	}
+	return nil, false
+}
+
+// ForEach (f: Lambda) Вызвать лямбду для каждого элемента потока
+func (s *Stream) ForEach(f dynamic.Lambda) error {
+	return s.run(func(flow *tFlow, out <-chan interface{}) {
+		i := 0
+		for e := range out {
+			flow.call("ForEach", i, f, e)
+			i++
+		}
+	})
+}
+
+// Collect Прочитать поток в коллекцию
+func (s *Stream) Collect() ([]interface{}, error) {
+	collection := make([]interface{}, 0)
+	err := s.run(func(flow *tFlow, out <-chan interface{}) {
+		for e := range out {
+			collection = append(collection, e)
+		}
+	})
+	return collection, err
+}
+
+// Functor Прочитать поток в функтор с контекстом потока
+func (s *Stream) Functor() (*Functor, error) {
+	collection, err := s.Collect()
+	return F(collection...).WithContext(s.ctx), err
+}
+
+// Sink (ch: chan<- any) Отправить элементы потока в канал; канал не закрывается
+func (s *Stream) Sink(ch chan<- interface{}) error {
+	return s.run(func(flow *tFlow, out <-chan interface{}) {
+		for e := range out {
+			if !send(flow.ctx, ch, e) {
+				return
+			}
+		}
+	})
+}
+
+// send Отправить элемент, если контекст не отменен раньше
+func send(ctx context.Context, out chan<- interface{}, e interface{}) bool {
+	select {
+	case out <- e:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,127 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package functor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func collect(t *testing.T, s *Stream) []interface{} {
+	t.Helper()
+	collection, err := s.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return collection
+}
+
+func TestStreamOperators(t *testing.T) {
+	double := func(e interface{}) interface{} { return e.(int) * 2 }
+	big := func(e interface{}) bool { return e.(int) > 4 }
+	equal(t, collect(t, S(numbers(10)...).Map(double).Filter(big).Skip(1).Take(3)), []interface{}{8, 10, 12})
+	pair := func(e interface{}) interface{} { return []interface{}{e, e} }
+	equal(t, collect(t, S(1, 2).FlatMap(pair)), []interface{}{1, 1, 2, 2})
+	equal(t, collect(t, S(1, 2, 3, 4, 5).Chunk(2)), []interface{}{TTuple{1, 2}, TTuple{3, 4}, TTuple{5}})
+	parity := func(e interface{}) interface{} { return e.(int) % 2 }
+	equal(t, collect(t, S(1, 3, 2, 4, 5).Distinct(parity)), []interface{}{1, 2})
+	equal(t, collect(t, S([]int{1}, []int{1}).Distinct()), []interface{}{[]int{1}})
+	values := colliding()
+	equal(t, collect(t, S(values...).Distinct()), values[:len(values)-1])
+	equal(t, collect(t, S(1, 2).Take(0)), []interface{}{})
+}
+
+func TestStreamLazy(t *testing.T) {
+	n := 0
+	s := Generate(func() (interface{}, bool) {
+		n++
+		return n, true
+	}).Map(func(e interface{}) interface{} { return e.(int) * 10 }).Take(3)
+	if n != 0 {
+		t.Fatalf("source read %d elements before the terminal operation", n)
+	}
+	equal(t, collect(t, s), []interface{}{10, 20, 30})
+	if n > 3+2 {
+		t.Fatalf("source read %d elements for Take(3)", n)
+	}
+}
+
+func TestStreamChannels(t *testing.T) {
+	in := make(chan interface{})
+	go func() {
+		for i := 1; i <= 3; i++ {
+			in <- i
+		}
+		close(in)
+	}()
+	out := make(chan interface{}, 3)
+	if err := From(in).Sink(out); err != nil {
+		t.Fatal(err)
+	}
+	close(out)
+	got := make([]interface{}, 0)
+	for e := range out {
+		got = append(got, e)
+	}
+	equal(t, got, []interface{}{1, 2, 3})
+
+	sum := 0
+	if err := S(1, 2, 3).ForEach(func(e interface{}) { sum += e.(int) }); err != nil || sum != 6 {
+		t.Fatalf("ForEach = %d, %v; want 6", sum, err)
+	}
+	f, err := F(1, 2, 3).Stream().Filter(func(e interface{}) bool { return e != 2 }).Functor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, root(t, f), []interface{}{1, 3})
+}
+
+func TestStreamContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	endless := Generate(func() (interface{}, bool) { return 1, true }).WithContext(ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- endless.ForEach(func(interface{}) { time.Sleep(time.Millisecond) })
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("ForEach = %v, want deadline exceeded", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream does not stop when its context expires")
+	}
+
+	cancelled, stop := context.WithCancel(context.Background())
+	stop()
+	if _, err := S(1, 2).WithContext(cancelled).Collect(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Collect = %v, want context canceled", err)
+	}
+	if _, err := F(1).WithContext(cancelled).Stream().Collect(); !errors.Is(err, context.Canceled) {
+		t.Fatal("functor stream does not inherit the context")
+	}
+}
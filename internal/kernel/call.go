@@ -0,0 +1,169 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package kernel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/x-research-team/bus"
+	"github.com/x-research-team/contract"
+	"github.com/x-research-team/kernel/internal/config"
+	"github.com/x-research-team/kernel/internal/dynamic"
+	"github.com/x-research-team/kernel/internal/functor"
+	"github.com/x-research-team/kernel/internal/tenant"
+)
+
+const (
+	// Route Маршрут команд самого ядра
+	Route = "kernel"
+	// CallTimeout Срок команды call, если он не задан в сообщении
+	CallTimeout = 30 * time.Second
+)
+
+// TCall Данные команды call: имя функции, аргументы массивом или объектом и срок вызова
+type TCall struct {
+	Function string           `json:"function"`
+	Args     json.RawMessage  `json:"args,omitempty"`
+	Timeout  config.TDuration `json:"timeout,omitempty"`
+}
+
+// TCallResult Ответ на команды ядра
+type TCallResult struct {
+	ID        string               `json:"id"`
+	Function  string               `json:"function,omitempty"`
+	Pipeline  string               `json:"pipeline,omitempty"`
+	Result    json.RawMessage      `json:"result,omitempty"`
+	Functions []*dynamic.TFunction `json:"functions,omitempty"`
+	Error     string               `json:"error,omitempty"`
+}
+
+// Functions Вызывать командой call функции реестра вместо общего dynamic.Functions
+func Functions(registry *dynamic.TRegistry) contract.KernelModule {
+	return func(s contract.IService) {
+		if kernel, ok := s.(*Kernel); ok {
+			kernel.functions = registry
+		}
+	}
+}
+
+// callable (t: string, names: ...string) Проверить, что арендатор t может вызывать функции names
+func (kernel *Kernel) callable(t string, names ...string) error {
+	for _, name := range names {
+		if !kernel.tenants.Callable(t, name) {
+			return fmt.Errorf("[Command] %v: function is not allowed for tenant %v", name, t)
+		}
+	}
+	return nil
+}
+
+// callables (t: string) Функции реестра, доступные арендатору t
+func (kernel *Kernel) callables(t string) []*dynamic.TFunction {
+	functions := make([]*dynamic.TFunction, 0)
+	for _, f := range kernel.functions.List() {
+		if kernel.tenants.Callable(t, f.Name) {
+			functions = append(functions, f)
+		}
+	}
+	return functions
+}
+
+// command Выполнить команду маршрута kernel и ответить в server
+//
+//	call       вызвать функцию реестра: {"function": "name", "args": [...], "timeout": "5s"}
+//	functions  перечислить доступные арендатору функции реестра с параметрами
+//	pipeline   выполнить конвейер config/pipelines/<name>.json над данными: {"pipeline": "name", "data": [...], "timeout": "5s", "stats": true};
+//	           результат - FunctorResult (со статистикой операторов при stats), ошибки элементов - в error
+func (kernel *Kernel) command(m contract.IMessage) {
+	result := &TCallResult{ID: m.ID().String()}
+	switch m.Command() {
+	case "call":
+		call := new(TCall)
+		if err := json.Unmarshal([]byte(m.Data()), call); err != nil {
+			result.Error = err.Error()
+			break
+		}
+		result.Function = call.Function
+		if err := kernel.callable(tenant.Of(m), call.Function); err != nil {
+			result.Error = err.Error()
+			break
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), call.Timeout.Or(CallTimeout))
+		v, err := kernel.functions.CallJSONContext(ctx, call.Function, call.Args)
+		cancel()
+		if err != nil {
+			result.Error = err.Error()
+			break
+		}
+		result.Result = v
+	case "functions":
+		result.Functions = kernel.callables(tenant.Of(m))
+	case "pipeline":
+		call := new(TPipelineCall)
+		if err := json.Unmarshal([]byte(m.Data()), call); err != nil {
+			result.Error = err.Error()
+			break
+		}
+		result.Pipeline = call.Pipeline
+		elements, err := call.elements()
+		if err != nil {
+			result.Error = err.Error()
+			break
+		}
+		p, err := kernel.pipeline(call.Pipeline)
+		if err != nil {
+			result.Error = err.Error()
+			break
+		}
+		if err := kernel.callable(tenant.Of(m), p.Functions()...); err != nil {
+			result.Error = err.Error()
+			break
+		}
+		run, err := p.Compile(kernel.functions)
+		if err != nil {
+			result.Error = err.Error()
+			break
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), call.Timeout.Or(CallTimeout))
+		f := run(functor.F(elements...).WithContext(ctx).Profile(call.Stats))
+		cancel()
+		result.Result = f.Result().ToJson()
+		if errs := f.Errors(); len(errs) != 0 {
+			result.Error = functor.TErrors(errs).Error()
+		}
+	default:
+		result.Error = fmt.Sprintf("unknown command (%v)", m.Command())
+	}
+	if result.Error != "" {
+		kernel.logger.Component("Kernel").Route(Route).Message(result.ID).Error("%v", result.Error)
+	}
+	buffer, err := json.Marshal(result)
+	if err != nil {
+		bus.Error <- err
+		return
+	}
+	kernel.signal(tenant.Message(tenant.Of(m), bus.Message("server", "response", string(buffer))))
+}
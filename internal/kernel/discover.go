@@ -0,0 +1,194 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package kernel
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/x-research-team/kernel/internal/config"
+)
+
+// Root Корень путей компонентов: родительский каталог рабочего, как в implant
+var Root = ".."
+
+// TFile Файл, найденный при поиске плагинов, и причина решения по нему
+type TFile struct {
+	Path   string
+	Reason string
+}
+
+// TDiscovery Результат поиска плагинов компонента
+type TDiscovery struct {
+	Matched  []string // Файлы для загрузки
+	Skipped  []TFile  // Файлы, не подходящие по расширению или уже загруженные
+	Rejected []TFile  // Пути и файлы, которые не удалось использовать
+}
+
+// Discover (c: config.TComponentConfig) Найти файлы плагинов компонента.
+// Каталог просматривается вместе с непосредственными подкаталогами,
+// с recursive - целиком.
+func Discover(c config.TComponentConfig) *TDiscovery {
+	d := &TDiscovery{Matched: make([]string, 0), Skipped: make([]TFile, 0), Rejected: make([]TFile, 0)}
+	root, err := filepath.Abs(Root)
+	if err != nil {
+		d.Rejected = append(d.Rejected, TFile{Path: string(c.Path), Reason: err.Error()})
+		return d
+	}
+	pattern := filepath.Join(root, filepath.FromSlash(string(c.Path)))
+	paths, err := glob(pattern)
+	if err != nil {
+		d.Rejected = append(d.Rejected, TFile{Path: pattern, Reason: err.Error()})
+		return d
+	}
+	if len(paths) == 0 {
+		d.Rejected = append(d.Rejected, TFile{Path: pattern, Reason: "no such file or directory"})
+		return d
+	}
+	types := c.Types
+	if len(types) == 0 {
+		types = config.TComponentTypes{"so"}
+	}
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			d.Rejected = append(d.Rejected, TFile{Path: p, Reason: err.Error()})
+			continue
+		}
+		if !info.IsDir() {
+			d.file(p, types, seen)
+			continue
+		}
+		base := strings.Count(p, string(filepath.Separator))
+		err = filepath.Walk(p, func(f string, info os.FileInfo, err error) error {
+			if err != nil {
+				d.Rejected = append(d.Rejected, TFile{Path: f, Reason: err.Error()})
+				return nil
+			}
+			depth := strings.Count(f, string(filepath.Separator)) - base
+			if info.IsDir() {
+				if !c.Recursive && depth > 1 {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			d.file(f, types, seen)
+			return nil
+		})
+		if err != nil {
+			d.Rejected = append(d.Rejected, TFile{Path: p, Reason: err.Error()})
+		}
+	}
+	return d
+}
+
+// file Отобрать файл по расширению
+func (d *TDiscovery) file(f string, types config.TComponentTypes, seen map[string]bool) {
+	if seen[f] {
+		return
+	}
+	seen[f] = true
+	ext := strings.TrimPrefix(filepath.Ext(f), ".")
+	for _, t := range types {
+		if string(t) == ext {
+			d.Matched = append(d.Matched, f)
+			return
+		}
+	}
+	d.Skipped = append(d.Skipped, TFile{Path: f, Reason: fmt.Sprintf("extension %q is not one of %v", ext, types)})
+}
+
+// glob Пути по шаблону filepath.Match, где сегмент ** соответствует любому числу каталогов
+func glob(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, err
+		}
+		if !strings.ContainsAny(pattern, "*?[") {
+			if _, err := os.Stat(pattern); err != nil {
+				return nil, nil
+			}
+			return []string{pattern}, nil
+		}
+		return filepath.Glob(pattern)
+	}
+	separator := string(filepath.Separator)
+	segments := strings.Split(pattern, separator)
+	static := 0
+	for static < len(segments) && !strings.ContainsAny(segments[static], "*?[") {
+		static++
+	}
+	base := strings.Join(segments[:static], separator)
+	if base == "" {
+		base = separator
+	}
+	matches := make([]string, 0)
+	err := filepath.Walk(base, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(base, p)
+		if err != nil || rel == "." {
+			return nil
+		}
+		ok, err := match(segments[static:], strings.Split(rel, separator))
+		if err != nil {
+			return err
+		}
+		if ok {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	sort.Strings(matches)
+	return matches, err
+}
+
+// match Сопоставить сегменты пути сегментам шаблона
+func match(pattern, name []string) (bool, error) {
+	if len(pattern) == 0 {
+		return len(name) == 0, nil
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			ok, err := match(pattern[1:], name[i:])
+			if ok || err != nil {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+	if len(name) == 0 {
+		return false, nil
+	}
+	ok, err := filepath.Match(pattern[0], name[0])
+	if !ok || err != nil {
+		return ok, err
+	}
+	return match(pattern[1:], name[1:])
+}
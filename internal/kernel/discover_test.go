@@ -0,0 +1,205 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package kernel
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/x-research-team/kernel/internal/config"
+)
+
+// plugins Каталог плагинов с файлами files; Root указывает на него до конца теста
+func plugins(t *testing.T, files ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, f := range files {
+		write(t, filepath.Join(root, filepath.FromSlash(f)), "")
+	}
+	old := Root
+	Root = root
+	t.Cleanup(func() { Root = old })
+	return root
+}
+
+// relative Пути относительно root через /
+func relative(t *testing.T, root string, paths []string) []string {
+	t.Helper()
+	list := make([]string, 0, len(paths))
+	for _, p := range paths {
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, filepath.ToSlash(rel))
+	}
+	return list
+}
+
+func TestDiscover(t *testing.T) {
+	root := plugins(t,
+		"system/server.so",
+		"system/README.md",
+		"system/storage/storage.so",
+		"system/storage/deep/dialect.so",
+		"billing/acme/v1/invoice.so",
+		"billing/acme/v1/invoice.dll",
+		"billing/globex/report.so",
+	)
+	cases := []struct {
+		name      string
+		component config.TComponentConfig
+		matched   []string
+		skipped   []string
+	}{
+		{
+			name:      "directory and its subdirectories",
+			component: config.TComponentConfig{Path: "system"},
+			matched:   []string{"system/server.so", "system/storage/storage.so"},
+			skipped:   []string{"system/README.md"},
+		},
+		{
+			name:      "recursive",
+			component: config.TComponentConfig{Path: "system", Recursive: true},
+			matched:   []string{"system/server.so", "system/storage/deep/dialect.so", "system/storage/storage.so"},
+			skipped:   []string{"system/README.md"},
+		},
+		{
+			name:      "file",
+			component: config.TComponentConfig{Path: "system/server.so"},
+			matched:   []string{"system/server.so"},
+		},
+		{
+			name:      "nested glob",
+			component: config.TComponentConfig{Path: "billing/**/*.so"},
+			matched:   []string{"billing/acme/v1/invoice.so", "billing/globex/report.so"},
+		},
+		{
+			name:      "nested glob with types",
+			component: config.TComponentConfig{Path: "billing/**/invoice.*", Types: config.TComponentTypes{"dll"}},
+			matched:   []string{"billing/acme/v1/invoice.dll"},
+			skipped:   []string{"billing/acme/v1/invoice.so"},
+		},
+		{
+			name:      "glob in the middle",
+			component: config.TComponentConfig{Path: "billing/*/v1/*.so"},
+			matched:   []string{"billing/acme/v1/invoice.so"},
+		},
+		{
+			// Каталог, найденный шаблоном, просматривается, а его файлы совпадают с шаблоном еще раз
+			name:      "duplicate plugins",
+			component: config.TComponentConfig{Path: "system/**", Recursive: true},
+			matched:   []string{"system/server.so", "system/storage/deep/dialect.so", "system/storage/storage.so"},
+			skipped:   []string{"system/README.md"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := Discover(c.component)
+			if len(d.Rejected) != 0 {
+				t.Fatalf("rejected %+v", d.Rejected)
+			}
+			matched := relative(t, root, d.Matched)
+			if c.matched == nil {
+				c.matched = []string{}
+			}
+			skipped := make([]string, 0, len(d.Skipped))
+			for _, f := range d.Skipped {
+				skipped = append(skipped, f.Path)
+			}
+			skipped = relative(t, root, skipped)
+			if c.skipped == nil {
+				c.skipped = []string{}
+			}
+			if !reflect.DeepEqual(matched, c.matched) {
+				t.Errorf("matched %v, want %v", matched, c.matched)
+			}
+			if !reflect.DeepEqual(skipped, c.skipped) {
+				t.Errorf("skipped %v, want %v", skipped, c.skipped)
+			}
+		})
+	}
+}
+
+func TestDiscoverRejected(t *testing.T) {
+	root := plugins(t, "system/server.so")
+	cases := []struct {
+		path, reason string
+	}{
+		{"missing", "no such file or directory"},
+		{"missing/**/*.so", "no such file or directory"},
+		{"system/*.dll", "no such file or directory"},
+		{"system/[", "syntax error in pattern"},
+		{"system/**/[", "syntax error in pattern"},
+	}
+	for _, c := range cases {
+		d := Discover(config.TComponentConfig{Path: config.TComponentPath(c.path)})
+		if len(d.Matched) != 0 || len(d.Rejected) != 1 {
+			t.Errorf("%v: matched %v, rejected %+v", c.path, d.Matched, d.Rejected)
+			continue
+		}
+		if r := d.Rejected[0]; r.Path != filepath.Join(root, filepath.FromSlash(c.path)) || !strings.Contains(r.Reason, c.reason) {
+			t.Errorf("%v: rejected %+v, want %q", c.path, r, c.reason)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"**", "", true},
+		{"**", "a", true},
+		{"**", "a/b/c", true},
+		{"**/*.so", "a.so", true},
+		{"**/*.so", "a/b/c.so", true},
+		{"**/*.so", "a/b/c.dll", false},
+		{"a/**", "a", true},
+		{"a/**", "b/c", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/x/y/c", false},
+		{"a/**/**/b", "a/x/b", true},
+		{"*/b", "a/b", true},
+		{"*/b", "a/x/b", false},
+		{"a/*", "a", false},
+		{"a", "a/b", false},
+		{"a?c/[xy]", "abc/y", true},
+	}
+	for _, c := range cases {
+		got, err := match(strings.Split(c.pattern, "/"), strings.Split(c.name, "/"))
+		if c.name == "" {
+			got, err = match(strings.Split(c.pattern, "/"), nil)
+		}
+		if err != nil || got != c.want {
+			t.Errorf("match(%q, %q) = %v, %v, want %v", c.pattern, c.name, got, err, c.want)
+		}
+	}
+	if _, err := match([]string{"**", "["}, []string{"a"}); err == nil {
+		t.Error("malformed pattern is accepted")
+	}
+}
@@ -0,0 +1,46 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package kernel
+
+import "github.com/x-research-team/kernel/internal/dynamic"
+
+// Examples (registry: *dynamic.TRegistry) Зарегистрировать функции example.*, на которые ссылается
+// конвейер config/pipelines/example.json
+func Examples(registry *dynamic.TRegistry) error {
+	functions := []struct {
+		name, description string
+		f                 dynamic.Lambda
+		params            []string
+	}{
+		{"example.positive", "x > 0", func(x float64) bool { return x > 0 }, []string{"x"}},
+		{"example.double", "x * 2", func(x float64) float64 { return x * 2 }, []string{"x"}},
+		{"example.sum", "acc + x", func(acc, x float64) float64 { return acc + x }, []string{"acc", "x"}},
+	}
+	for _, fn := range functions {
+		if err := registry.Register(fn.name, fn.description, fn.f, fn.params...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package kernel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/x-research-team/kernel/internal/config"
+	"github.com/x-research-team/kernel/internal/functor"
+)
+
+// PipelinesDir Каталог конвейеров внутри каталога конфигурации
+const PipelinesDir = "pipelines"
+
+// TPipelineCall Данные команды pipeline: имя конвейера, элементы, срок выполнения и запрос статистики
+type TPipelineCall struct {
+	Pipeline string           `json:"pipeline"`
+	Data     json.RawMessage  `json:"data,omitempty"` // Массив элементов или один элемент
+	Timeout  config.TDuration `json:"timeout,omitempty"`
+	Stats    bool             `json:"stats,omitempty"` // Вернуть статистику операторов (см. functor.Profile)
+}
+
+// elements Элементы коллекции из данных команды
+func (call *TPipelineCall) elements() ([]interface{}, error) {
+	data := bytes.TrimSpace(call.Data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return []interface{}{}, nil
+	}
+	if data[0] == '[' {
+		elements := make([]interface{}, 0)
+		err := json.Unmarshal(data, &elements)
+		return elements, err
+	}
+	var element interface{}
+	err := json.Unmarshal(data, &element)
+	return []interface{}{element}, err
+}
+
+// pipeline (name: string) Прочитать конвейер <dir>/pipelines/<name>.json и проверить его по схеме
+// pipeline.json; файл профиля <dir>/profiles/<profile>/pipelines/<name>.json заменяет его целиком
+func (kernel *Kernel) pipeline(name string) (*functor.TPipeline, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("[Pipeline] invalid name (%v)", name)
+	}
+	dir, profile := config.Directory(), ""
+	if current := kernel.configuration(); current != nil {
+		dir, profile = current.Dir, current.Profile
+	}
+	paths := []string{filepath.Join(dir, PipelinesDir, name+".json")}
+	if profile != "" {
+		paths = append([]string{filepath.Join(dir, config.ProfilesDir, profile, PipelinesDir, name+".json")}, paths...)
+	}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := config.Check("pipeline.json", path, data); err != nil {
+			return nil, err
+		}
+		p := &functor.TPipeline{Name: name}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(p); err != nil {
+			return nil, fmt.Errorf("[Pipeline] %s: %v", path, err)
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("[Pipeline] %v not found in %v", name, filepath.Join(dir, PipelinesDir))
+}
@@ -0,0 +1,118 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package kernel
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/x-research-team/kernel/internal/config"
+	"github.com/x-research-team/kernel/internal/dynamic"
+	"github.com/x-research-team/kernel/internal/functor"
+	"github.com/x-research-team/kernel/internal/tenant"
+)
+
+// directory Ядро с каталогом конфигурации dir и профилем profile
+func directory(dir, profile string) *Kernel {
+	return &Kernel{config: &config.TKernelConfig{Dir: dir, Profile: profile}}
+}
+
+func write(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExamplePipeline(t *testing.T) {
+	p, err := directory(filepath.Join("..", "..", "config"), "").pipeline("example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := dynamic.Registry()
+	if err := Examples(registry); err != nil {
+		t.Fatal(err)
+	}
+	f, err := p.Run(context.Background(), registry, 1.0, -2.0, 3.0, 4.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := f.Result()
+	if !reflect.DeepEqual(result.Root, []interface{}{2.0, 6.0, 8.0}) || len(result.Branches) != 2 {
+		t.Fatalf("result %+v", result)
+	}
+	if sum := result.Branches[0].(*functor.FunctorResult).Root; !reflect.DeepEqual(sum, []interface{}{16.0}) {
+		t.Fatalf("sum branch %v", sum)
+	}
+}
+
+func TestPipelineFiles(t *testing.T) {
+	dir := t.TempDir()
+	write(t, filepath.Join(dir, PipelinesDir, "valid.json"), `{"steps": [{"operator": "take", "n": 1}]}`)
+	write(t, filepath.Join(dir, PipelinesDir, "invalid.json"), "{\n  \"steps\": [\n    {\"operator\": \"map\", \"mode\": 1}\n  ]\n}")
+	write(t, filepath.Join(dir, config.ProfilesDir, "dev", PipelinesDir, "valid.json"), `{"steps": [{"operator": "skip", "n": 1}]}`)
+
+	if p, err := directory(dir, "").pipeline("valid"); err != nil || p.Steps[0].Operator != "take" || p.Name != "valid" {
+		t.Fatalf("pipeline = %+v, %v", p, err)
+	}
+	if p, err := directory(dir, "dev").pipeline("valid"); err != nil || p.Steps[0].Operator != "skip" {
+		t.Fatalf("profile pipeline = %+v, %v", p, err)
+	}
+	_, err := directory(dir, "").pipeline("invalid")
+	if err == nil || !strings.Contains(err.Error(), "invalid.json:3:33") {
+		t.Fatalf("schema error %v, want the position of mode", err)
+	}
+	for _, name := range []string{"", "missing", "../valid", ".valid", "pipelines/valid"} {
+		if _, err := directory(dir, "").pipeline(name); err == nil {
+			t.Errorf("pipeline %q is read", name)
+		}
+	}
+}
+
+func TestPipelineFunctionsAllowed(t *testing.T) {
+	registry, err := tenant.Registry(tenant.TConfigs{{Name: "acme", Functions: []string{"example.*"}}, {Name: "globex", Functions: []string{"example.sum"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kernel := directory(filepath.Join("..", "..", "config"), "")
+	kernel.tenants = registry
+	p, err := kernel.pipeline("example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kernel.callable("acme", p.Functions()...); err != nil {
+		t.Fatal(err)
+	}
+	if err := kernel.callable("globex", p.Functions()...); err == nil || !strings.Contains(err.Error(), "example.positive") {
+		t.Fatalf("globex may run the pipeline: %v", err)
+	}
+}
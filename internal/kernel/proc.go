@@ -25,26 +25,49 @@ package kernel
 
 import (
 	"fmt"
+	"plugin"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/x-research-team/bus"
 	"github.com/x-research-team/contract"
-	"github.com/x-research-team/implant"
+	"github.com/x-research-team/kernel/internal/config"
+	"github.com/x-research-team/kernel/internal/dynamic"
+	"github.com/x-research-team/kernel/internal/logger"
+	"github.com/x-research-team/kernel/internal/schema"
+	"github.com/x-research-team/kernel/internal/tenant"
 	"github.com/x-research-team/vm"
 )
 
 // Kernel Сервис биллинга
 type Kernel struct {
+	m          sync.RWMutex
 	components map[string]contract.IComponent // Набор компонентов ядра
+	owners     map[string]string              // Арендатор компонента, пустой для общих
+	plugins    map[string][]string            // Компоненты, загруженные из плагина
+	files      map[string]string              // Путь плагина, из которого загружен файл
+	schemas    *schema.TRegistry              // Схемы сообщений для проверки при маршрутизации
+	tenants    *tenant.TRegistry              // Арендаторы и разрешенные между ними маршруты
+	logger     *logger.TLogger                // Структурированный лог маршрутизации
+	functions  *dynamic.TRegistry             // Функции, вызываемые командой call
+
+	config   *config.TKernelConfig // Текущая конфигурация для горячей перезагрузки
+	interval time.Duration         // Период опроса каталога конфигурации
 
 	uuid string
 }
 
 // New Создать экземпляр сервиса биллинга
 func New(opts ...contract.KernelModule) contract.IService {
-	b := &Kernel{components: make(map[string]contract.IComponent)}
+	b := &Kernel{
+		components: make(map[string]contract.IComponent),
+		owners:     make(map[string]string),
+		plugins:    make(map[string][]string),
+		files:      make(map[string]string),
+		functions:  dynamic.Functions,
+	}
 	for _, o := range opts {
 		o(b)
 	}
@@ -56,28 +79,177 @@ func New(opts ...contract.KernelModule) contract.IService {
 	return b
 }
 
+// Validate Проверять сообщения по реестру схем перед маршрутизацией
+func Validate(registry *schema.TRegistry) contract.KernelModule {
+	return func(s contract.IService) {
+		if kernel, ok := s.(*Kernel); ok {
+			kernel.schemas = registry
+		}
+	}
+}
+
+// Tenants Изолировать маршрутизацию сообщений по арендаторам
+func Tenants(registry *tenant.TRegistry) contract.KernelModule {
+	return func(s contract.IService) {
+		if kernel, ok := s.(*Kernel); ok {
+			kernel.tenants = registry
+		}
+	}
+}
+
+// Logger Писать записи маршрутизации в структурированный лог
+func Logger(l *logger.TLogger) contract.KernelModule {
+	return func(s contract.IService) {
+		if kernel, ok := s.(*Kernel); ok {
+			kernel.logger = l
+		}
+	}
+}
+
 // AddPlugin Добавить плагин на горячем ходу
 func (kernel *Kernel) AddPlugin(p, name string) error {
-	implant.Init(p)
-	for _, o := range implant.Modules() {
-		o(kernel)
+	c := config.TComponentConfig{Path: config.TComponentPath(p), Enabled: true}
+	if current := kernel.configuration(); current != nil {
+		c.Types = current.Extensions
+	}
+	return kernel.addPlugin(c)
+}
+
+// Plugin (c: config.TComponentConfig) Загрузить компоненты плагина при создании ядра
+func Plugin(c config.TComponentConfig) contract.KernelModule {
+	return func(s contract.IService) {
+		if kernel, ok := s.(*Kernel); ok {
+			if _, err := kernel.load(c); err != nil {
+				bus.Error <- err
+			}
+		}
+	}
+}
+
+// addPlugin Загрузить плагин и запустить его новые компоненты
+func (kernel *Kernel) addPlugin(c config.TComponentConfig) error {
+	added, err := kernel.load(c)
+	if err != nil {
+		return err
+	}
+	for _, component := range added {
+		bus.Info <- fmt.Sprintf("[Kernel] %v added from %v", component.Name(), c.Path)
+		go kernel.run(component)
 	}
-	kernel.run(kernel.components[name])
 	return nil
 }
 
-// RemovePlugin Удалить плагин на горячем ходу
+// load Найти файлы плагина, загрузить их и зарегистрировать компоненты
+func (kernel *Kernel) load(c config.TComponentConfig) ([]contract.IComponent, error) {
+	p := string(c.Path)
+	d := Discover(c)
+	modules := make(contract.KernelModules, 0, len(d.Matched))
+	for _, f := range d.Matched {
+		kernel.m.RLock()
+		owner, loaded := kernel.files[f]
+		kernel.m.RUnlock()
+		if loaded {
+			d.Skipped = append(d.Skipped, TFile{Path: f, Reason: fmt.Sprintf("already loaded from %v", owner)})
+			continue
+		}
+		module, err := open(f)
+		if err != nil {
+			d.Rejected = append(d.Rejected, TFile{Path: f, Reason: err.Error()})
+			continue
+		}
+		bus.Info <- fmt.Sprintf("[Kernel] %v: matched %v", p, f)
+		kernel.m.Lock()
+		kernel.files[f] = p
+		kernel.m.Unlock()
+		modules = append(modules, module)
+	}
+	for _, f := range d.Skipped {
+		bus.Info <- fmt.Sprintf("[Kernel] %v: skipped %v: %v", p, f.Path, f.Reason)
+	}
+	for _, f := range d.Rejected {
+		bus.Error <- fmt.Errorf("[Kernel] %v: rejected %v: %v", p, f.Path, f.Reason)
+	}
+	if len(modules) == 0 {
+		return nil, fmt.Errorf("[Kernel] no components found in %v", p)
+	}
+	kernel.m.RLock()
+	before := make(map[string]bool, len(kernel.components))
+	for k := range kernel.components {
+		before[k] = true
+	}
+	kernel.m.RUnlock()
+	tenant.Bind(c.Tenant, modules...)(kernel)
+	kernel.m.Lock()
+	added := make([]contract.IComponent, 0)
+	for k, component := range kernel.components {
+		if !before[k] {
+			kernel.plugins[p] = append(kernel.plugins[p], k)
+			added = append(added, component)
+		}
+	}
+	kernel.m.Unlock()
+	return added, nil
+}
+
+// open Открыть файл плагина и получить его модуль из символа Init
+func open(f string) (contract.KernelModule, error) {
+	lib, err := plugin.Open(f)
+	if err != nil {
+		return nil, err
+	}
+	symbol, err := lib.Lookup("Init")
+	if err != nil {
+		return nil, err
+	}
+	constructor, ok := symbol.(func() contract.KernelModule)
+	if !ok {
+		return nil, fmt.Errorf("Init is %T, not func() contract.KernelModule", symbol)
+	}
+	return constructor(), nil
+}
+
+// RemovePlugin Удалить плагин (по пути) или компонент (по имени) на горячем ходу
 func (kernel *Kernel) RemovePlugin(name string) error {
-	delete(kernel.components, name)
+	kernel.m.Lock()
+	names, ok := kernel.plugins[name]
+	if !ok {
+		names = []string{name}
+	}
+	delete(kernel.plugins, name)
+	for f, owner := range kernel.files {
+		if owner == name {
+			delete(kernel.files, f)
+		}
+	}
+	removed := make([]contract.IComponent, 0, len(names))
+	for _, n := range names {
+		if c, ok := kernel.components[n]; ok {
+			removed = append(removed, c)
+		}
+		delete(kernel.components, n)
+		delete(kernel.owners, n)
+	}
+	kernel.m.Unlock()
+	for _, c := range removed {
+		if err := c.Stop(); err != nil {
+			bus.Error <- err
+		}
+		bus.Info <- fmt.Sprintf("[Kernel] %v removed", c.Name())
+	}
 	return nil
 }
 
 // Run Запуск сервиса биллинга
 func (kernel *Kernel) Run() error {
 	kernel.uuid = uuid.New().String()
+	kernel.m.RLock()
 	for n := range kernel.components {
 		go kernel.run(kernel.components[n])
 	}
+	kernel.m.RUnlock()
+	if kernel.configuration() != nil {
+		go kernel.watch()
+	}
 	bus.Info <- "[Kernel] Service started"
 	for true {
 		for signal := range bus.Signals.Generate() {
@@ -94,7 +266,26 @@ func (kernel *Kernel) signal(m contract.IMessage) {
 		bus.Error <- fmt.Errorf("route %v is not a found", route)
 		return
 	}
+	log := kernel.logger.Component("Kernel").Route(route).Message(m.ID().String())
+	if err := kernel.schemas.Validate(route, m.Command(), []byte(m.Data())); err != nil {
+		log.Error("message rejected: %v", err)
+		return
+	}
+	from := tenant.Of(m)
+	if from != tenant.Shared {
+		log = log.With("tenant", from)
+	}
+	log.With("command", m.Command()).Debug("routing message")
+	if route == Route {
+		go kernel.command(m)
+		return
+	}
+	kernel.m.RLock()
+	defer kernel.m.RUnlock()
 	for k := range kernel.components {
+		if !kernel.tenants.Allowed(from, kernel.owners[k]) {
+			continue
+		}
 		go kernel.handle(kernel.components[k], m)
 	}
 }
@@ -123,9 +314,24 @@ func (kernel *Kernel) handle(c contract.IComponent, message contract.IMessage) {
 }
 
 func (kernel *Kernel) AddComponent(c contract.IComponent) {
+	kernel.m.Lock()
+	defer kernel.m.Unlock()
 	kernel.components[c.Name()] = c
 }
 
+// AddTenantComponent Добавить компонент, доступный только арендатору
+func (kernel *Kernel) AddTenantComponent(t string, c contract.IComponent) {
+	if t == tenant.Shared {
+		kernel.AddComponent(c)
+		return
+	}
+	k := t + "/" + c.Name()
+	kernel.m.Lock()
+	defer kernel.m.Unlock()
+	kernel.components[k] = c
+	kernel.owners[k] = t
+}
+
 func (kernel *Kernel) Pid() string {
 	return kernel.uuid
 }
@@ -0,0 +1,183 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package kernel
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/x-research-team/bus"
+	"github.com/x-research-team/contract"
+	"github.com/x-research-team/kernel/internal/config"
+	"github.com/x-research-team/kernel/internal/dynamic"
+	"github.com/x-research-team/kernel/internal/logger"
+)
+
+// IReconfigurable Компонент, применяющий изменения конфигурации на горячем ходу
+type IReconfigurable interface {
+	// Reconfigure Применить изменения и вернуть ключи примененных изменений
+	Reconfigure(c *config.TKernelConfig, changes config.TChanges) []string
+}
+
+// Reload Перезагружать конфигурацию по SIGHUP и при изменении файлов каталога
+func Reload(c *config.TKernelConfig) contract.KernelModule {
+	return func(s contract.IService) {
+		if kernel, ok := s.(*Kernel); ok {
+			kernel.setConfiguration(c)
+			kernel.interval = c.Reload.Or(5 * time.Second)
+		}
+	}
+}
+
+// configuration Текущая конфигурация или nil, если ядро создано без Reload
+func (kernel *Kernel) configuration() *config.TKernelConfig {
+	kernel.m.RLock()
+	defer kernel.m.RUnlock()
+	return kernel.config
+}
+
+func (kernel *Kernel) setConfiguration(c *config.TKernelConfig) {
+	kernel.m.Lock()
+	defer kernel.m.Unlock()
+	kernel.config = c
+}
+
+// watch Ожидание SIGHUP и изменений в каталоге конфигурации
+func (kernel *Kernel) watch() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	files := config.Watch(kernel.configuration().Dir, kernel.interval, nil)
+	for {
+		select {
+		case <-hup:
+			bus.Info <- "[Kernel] SIGHUP received, reloading configuration"
+		case <-files:
+			bus.Info <- "[Kernel] configuration files changed, reloading configuration"
+		}
+		if err := kernel.reload(); err != nil {
+			bus.Error <- err
+		}
+	}
+}
+
+// reload Пересчитать конфигурацию и применить безопасные изменения
+func (kernel *Kernel) reload() error {
+	current := kernel.configuration()
+	c, err := config.Load(current.Dir, current.Profile)
+	if err != nil {
+		return fmt.Errorf("[Kernel] configuration is not reloaded: %v", err)
+	}
+	changes := config.Diff(current, c)
+	if len(changes) == 0 {
+		return nil
+	}
+	applied := make(map[string]bool)
+	for _, change := range changes {
+		switch change.Key {
+		case "log.level", "log.components":
+			logger.Forward(c.Log.Level)
+			kernel.logger.Configure(c.Log)
+			applied[change.Key] = true
+		case "log.trace":
+			if err := dynamic.Tracing(c.Log.Trace); err != nil {
+				bus.Error <- fmt.Errorf("[Kernel] %v", err)
+				continue
+			}
+			applied[change.Key] = true
+		case "components", "extensions":
+			applied[change.Key] = true
+		}
+	}
+	if (applied["components"] || applied["extensions"]) && !kernel.reconcile(current, c) {
+		applied["components"], applied["extensions"] = false, false
+	}
+	kernel.m.RLock()
+	components := make([]contract.IComponent, 0, len(kernel.components))
+	for _, component := range kernel.components {
+		components = append(components, component)
+	}
+	kernel.m.RUnlock()
+	for _, component := range components {
+		if r, ok := component.(IReconfigurable); ok {
+			for _, key := range r.Reconfigure(c, changes) {
+				applied[key] = true
+			}
+		}
+	}
+	restart := make([]string, 0)
+	for _, change := range changes {
+		if applied[change.Key] {
+			bus.Info <- fmt.Sprintf("[Kernel] applied %v", change)
+			continue
+		}
+		restart = append(restart, change.Key)
+	}
+	if len(restart) != 0 {
+		bus.Info <- fmt.Sprintf("[Kernel] restart required to apply: %v", strings.Join(restart, ", "))
+	}
+	kernel.setConfiguration(c)
+	return nil
+}
+
+// reconcile Загрузить новые и выгрузить отключенные компоненты. Плагин Go нельзя выгрузить
+// и загрузить заново, поэтому компоненты с измененной конфигурацией остаются прежними;
+// false, если такие есть и нужен перезапуск
+func (kernel *Kernel) reconcile(old, new *config.TKernelConfig) bool {
+	before, after := enabled(old), enabled(new)
+	complete := true
+	for p, c := range before {
+		n, ok := after[p]
+		switch {
+		case !ok:
+			if err := kernel.RemovePlugin(p); err != nil {
+				bus.Error <- err
+			}
+		case n.String() != c.String():
+			bus.Info <- fmt.Sprintf("[Kernel] plugin (%v) changed, restart required to reload it", p)
+			complete = false
+		}
+	}
+	for p, c := range after {
+		if _, ok := before[p]; !ok {
+			if err := kernel.addPlugin(c); err != nil {
+				bus.Error <- err
+			}
+		}
+	}
+	return complete
+}
+
+// enabled Включенные компоненты по пути с разрешенными расширениями
+func enabled(c *config.TKernelConfig) map[string]config.TComponentConfig {
+	components := make(map[string]config.TComponentConfig)
+	for _, component := range c.Components.Enabled() {
+		component.Types = component.Extensions(c.Extensions)
+		components[string(component.Path)] = component
+	}
+	return components
+}
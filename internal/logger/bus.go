@@ -0,0 +1,123 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package logger
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/x-research-team/bus"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/x-research-team/kernel/internal/config"
+)
+
+// prefix Компонент в начале сообщения: "[Storage] component started"
+var prefix = regexp.MustCompile(`^\[([^\]]+)\]\s*`)
+
+// Bus Писать в логгер сообщения шин bus.Sys, bus.Error, bus.Info и bus.Debug.
+// Шины читаются всегда, поэтому отправители не блокируются на отключенных уровнях.
+func (l *TLogger) Bus() {
+	go func() {
+		for v := range bus.Sys {
+			l.bus(Sys, v)
+		}
+	}()
+	go func() {
+		for v := range bus.Error {
+			l.bus(Error, v)
+		}
+	}()
+	go func() {
+		for v := range bus.Info {
+			l.bus(Info, v)
+		}
+	}()
+	go func() {
+		for v := range bus.Debug {
+			l.bus(Debug, v)
+		}
+	}()
+}
+
+func (l *TLogger) bus(level string, v interface{}) {
+	e := &TEntry{Level: level, Message: fmt.Sprint(v)}
+	if m := prefix.FindStringSubmatch(e.Message); m != nil {
+		e.Component, e.Message = m[1], e.Message[len(m[0]):]
+	}
+	l.Log(e)
+}
+
+// Open (c: *config.TLogConfig, journal) Логгер с приемниками из конфигурации.
+// journal возвращает базу данных соединения для приемника journal.
+func Open(c *config.TLogConfig, journal func(connection string) (*mongo.Database, error)) (*TLogger, error) {
+	sinks := make([]ISink, 0, len(c.Sinks))
+	fail := func(err error) (*TLogger, error) {
+		for _, sink := range sinks {
+			sink.Close()
+		}
+		return nil, err
+	}
+	if _, err := Format(new(TEntry), c.Format); err != nil {
+		return fail(err)
+	}
+	for i, s := range c.Sinks {
+		format := s.Format
+		if format == "" {
+			format = c.Format
+		}
+		if _, err := Format(new(TEntry), format); err != nil {
+			return fail(fmt.Errorf("sinks[%d]: %v", i, err))
+		}
+		switch s.Type {
+		case "stdout":
+			sinks = append(sinks, Stdout(format))
+		case "file":
+			if s.Path == "" {
+				return fail(fmt.Errorf("sinks[%d]: path is required", i))
+			}
+			sink, err := File(s.Path, format, s.MaxSize, s.MaxAge.Or(0), s.Backups)
+			if err != nil {
+				return fail(fmt.Errorf("sinks[%d]: %v", i, err))
+			}
+			sinks = append(sinks, sink)
+		case "journal":
+			db, err := journal(s.Connection)
+			if err != nil {
+				return fail(fmt.Errorf("sinks[%d]: %v", i, err))
+			}
+			collection := s.Collection
+			if collection == "" {
+				collection = "logs"
+			}
+			sinks = append(sinks, Journal(db.Collection(collection)))
+		default:
+			return fail(fmt.Errorf("sinks[%d]: unknown sink type %q", i, s.Type))
+		}
+	}
+	if len(sinks) == 0 {
+		sinks = append(sinks, Stdout(c.Format))
+	}
+	return New(c, sinks...), nil
+}
@@ -0,0 +1,94 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Форматы строк
+const (
+	JSON   = "json"
+	Logfmt = "logfmt"
+)
+
+// Format (e: *TEntry, format: string) Строка записи с переводом строки в формате json или logfmt
+func Format(e *TEntry, format string) ([]byte, error) {
+	switch format {
+	case JSON:
+		buffer, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		return append(buffer, '\n'), nil
+	case Logfmt, "":
+		return logfmt(e), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+func logfmt(e *TEntry) []byte {
+	var b bytes.Buffer
+	pair := func(k string, v interface{}) {
+		s := fmt.Sprint(v)
+		if s == "" {
+			return
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		if strings.ContainsAny(s, " =\"\t\r\n") {
+			s = strconv.Quote(s)
+		}
+		b.WriteString(s)
+	}
+	if !e.Time.IsZero() {
+		pair("time", e.Time.Format(time.RFC3339Nano))
+	}
+	pair("level", e.Level)
+	pair("component", e.Component)
+	pair("route", e.Route)
+	pair("message_id", e.MessageID)
+	pair("trace_id", e.TraceID)
+	pair("msg", e.Message)
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		pair(k, e.Fields[k])
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
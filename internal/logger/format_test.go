@@ -0,0 +1,65 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package logger
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	e := &TEntry{
+		Time:      time.Date(2021, 3, 7, 10, 45, 12, 500, time.UTC),
+		Level:     Info,
+		Component: "Storage",
+		TraceID:   "t-1",
+		Message:   `stored "users"`,
+		Fields:    TFields{"rows": 3, "table": "users", "query": "a = 1", "empty": ""},
+	}
+	cases := []struct {
+		name   string
+		entry  *TEntry
+		format string
+		want   string
+	}{
+		{"logfmt", e, Logfmt, `time=2021-03-07T10:45:12.0000005Z level=info component=Storage trace_id=t-1 msg="stored \"users\"" query="a = 1" rows=3 table=users` + "\n"},
+		{"default", &TEntry{Level: Debug, Message: "x"}, "", "level=debug msg=x\n"},
+		{"json", e, JSON, `{"time":"2021-03-07T10:45:12.0000005Z","level":"info","component":"Storage","trace_id":"t-1","message":"stored \"users\"","fields":{"empty":"","query":"a = 1","rows":3,"table":"users"}}` + "\n"},
+	}
+	for _, c := range cases {
+		got, err := Format(c.entry, c.format)
+		if err != nil || string(got) != c.want {
+			t.Errorf("%v:\n got %s, %v\nwant %s", c.name, got, err, c.want)
+		}
+	}
+	if _, err := Format(e, "xml"); err == nil || err.Error() != `unknown log format "xml"` {
+		t.Errorf("Format(xml) = %v", err)
+	}
+	line, _ := Format(e, JSON)
+	var back TEntry
+	if err := json.Unmarshal(line, &back); err != nil || !back.Time.Equal(e.Time) || back.Message != e.Message {
+		t.Errorf("json line does not round trip: %+v, %v", back, err)
+	}
+}
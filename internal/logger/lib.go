@@ -0,0 +1,255 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package logger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/x-research-team/bus"
+	"github.com/x-research-team/kernel/internal/config"
+)
+
+// Уровни записей
+const (
+	Sys   = "sys"
+	Error = "error"
+	Info  = "info"
+	Debug = "debug"
+)
+
+// TFields Дополнительные поля записи
+type TFields map[string]interface{}
+
+// TEntry Запись лога
+type TEntry struct {
+	Time      time.Time `json:"time" bson:"time"`
+	Level     string    `json:"level" bson:"level"`
+	Component string    `json:"component,omitempty" bson:"component,omitempty"`
+	Route     string    `json:"route,omitempty" bson:"route,omitempty"`
+	MessageID string    `json:"message_id,omitempty" bson:"message_id,omitempty"`
+	TraceID   string    `json:"trace_id,omitempty" bson:"trace_id,omitempty"`
+	Message   string    `json:"message" bson:"message"`
+	Fields    TFields   `json:"fields,omitempty" bson:"fields,omitempty"`
+}
+
+// ISink Приемник записей
+type ISink interface {
+	Write(e *TEntry) error
+	Close() error
+}
+
+// TLogger Структурированный логгер с уровнями по компонентам
+type TLogger struct {
+	m          sync.RWMutex
+	level      config.TLogLevel
+	components map[string]config.TLogLevel
+	sinks      []ISink
+}
+
+// New (c: *config.TLogConfig, sinks ...ISink) Логгер с уровнями из конфигурации
+func New(c *config.TLogConfig, sinks ...ISink) *TLogger {
+	l := &TLogger{sinks: sinks}
+	l.Configure(c)
+	return l
+}
+
+// Configure (c: *config.TLogConfig) Применить уровни на горячем ходу
+func (l *TLogger) Configure(c *config.TLogConfig) {
+	if l == nil {
+		return
+	}
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.level = c.Level
+	l.components = c.Components
+}
+
+// Enabled Пишутся ли записи уровня level компонента
+func (l *TLogger) Enabled(component, level string) bool {
+	if level == Sys {
+		return true
+	}
+	l.m.RLock()
+	defer l.m.RUnlock()
+	if levels, ok := l.components[component]; ok {
+		return levels[config.TLogLevelType(level)]
+	}
+	return l.level[config.TLogLevelType(level)]
+}
+
+// Log Записать запись во все приемники; секреты в сообщении и полях скрываются
+func (l *TLogger) Log(e *TEntry) {
+	if l == nil || !l.Enabled(e.Component, e.Level) {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Message = config.Redact(e.Message)
+	for k, v := range e.Fields {
+		if s, ok := v.(string); ok {
+			e.Fields[k] = config.Redact(s)
+		}
+	}
+	l.m.RLock()
+	defer l.m.RUnlock()
+	for _, sink := range l.sinks {
+		if err := sink.Write(e); err != nil {
+			fmt.Fprintf(os.Stderr, "[SYS] log sink: %v\n", err)
+		}
+	}
+}
+
+// Close Закрыть приемники
+func (l *TLogger) Close() error {
+	l.m.Lock()
+	defer l.m.Unlock()
+	var first error
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Component (name: string) Запись от имени компонента
+func (l *TLogger) Component(name string) *TRecord {
+	return &TRecord{logger: l, entry: TEntry{Component: name}}
+}
+
+// TRecord Построитель записи
+type TRecord struct {
+	logger *TLogger
+	entry  TEntry
+}
+
+// Route Маршрут сообщения
+func (r *TRecord) Route(route string) *TRecord {
+	r.entry.Route = route
+	return r
+}
+
+// Message Идентификатор сообщения
+func (r *TRecord) Message(id string) *TRecord {
+	r.entry.MessageID = id
+	return r
+}
+
+// Trace Идентификатор трассировки
+func (r *TRecord) Trace(id string) *TRecord {
+	r.entry.TraceID = id
+	return r
+}
+
+// With Дополнительное поле
+func (r *TRecord) With(key string, value interface{}) *TRecord {
+	if r.entry.Fields == nil {
+		r.entry.Fields = make(TFields)
+	}
+	r.entry.Fields[key] = value
+	return r
+}
+
+func (r *TRecord) log(level string, format string, args ...interface{}) {
+	e := r.entry
+	if e.Fields != nil {
+		e.Fields = make(TFields, len(r.entry.Fields))
+		for k, v := range r.entry.Fields {
+			e.Fields[k] = v
+		}
+	}
+	e.Level = level
+	if len(args) == 0 {
+		e.Message = format
+	} else {
+		e.Message = fmt.Sprintf(format, args...)
+	}
+	if r.logger == nil {
+		forward(&e)
+		return
+	}
+	r.logger.Log(&e)
+}
+
+// forward Отправить запись в шину bus, если логгер не задан
+func forward(e *TEntry) {
+	line := strings.TrimSuffix(string(logfmt(&TEntry{Route: e.Route, MessageID: e.MessageID, TraceID: e.TraceID, Fields: e.Fields})), "\n")
+	message := e.Message
+	if e.Component != "" {
+		message = fmt.Sprintf("[%s] %s", e.Component, message)
+	}
+	if line != "" {
+		message += " " + line
+	}
+	if !forwards(e.Level) {
+		return
+	}
+	switch e.Level {
+	case Error:
+		bus.Error <- message
+	case Info:
+		bus.Info <- message
+	case Debug:
+		bus.Debug <- message
+	}
+}
+
+// forwarded Уровни записей, отправляемых в шину bus (см. Forward)
+var forwarded atomic.Value
+
+// Forward (level: config.TLogLevel) Изменить на горячем ходу уровни записей, отправляемых в шину bus
+// без логгера. До первого вызова используются уровни bus.Trace из конфигурации шины
+func Forward(level config.TLogLevel) {
+	levels := make(config.TLogLevel, len(level))
+	for k, v := range level {
+		levels[k] = v
+	}
+	forwarded.Store(levels)
+}
+
+// forwards Отправляются ли в шину записи уровня level
+func forwards(level string) bool {
+	if levels, ok := forwarded.Load().(config.TLogLevel); ok {
+		return levels[config.TLogLevelType(level)]
+	}
+	switch level {
+	case Error:
+		return bus.Trace.Error
+	case Info:
+		return bus.Trace.Info
+	case Debug:
+		return bus.Trace.Debug
+	}
+	return false
+}
+
+func (r *TRecord) Error(format string, args ...interface{}) { r.log(Error, format, args...) }
+func (r *TRecord) Info(format string, args ...interface{})  { r.log(Info, format, args...) }
+func (r *TRecord) Debug(format string, args ...interface{}) { r.log(Debug, format, args...) }
@@ -0,0 +1,160 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package logger
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/x-research-team/kernel/internal/config"
+	"github.com/x-research-team/kernel/internal/dynamic"
+)
+
+// tMemory Приемник, запоминающий записи
+type tMemory struct {
+	m       sync.Mutex
+	entries []TEntry
+}
+
+func (s *tMemory) Write(e *TEntry) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.entries = append(s.entries, *e)
+	return nil
+}
+
+func (s *tMemory) Close() error { return nil }
+
+// logged Логгер с уровнями level, уровнями компонентов components и приемником в памяти
+func logged(level config.TLogLevel, components map[string]config.TLogLevel) (*TLogger, *tMemory) {
+	sink := new(tMemory)
+	return New(&config.TLogConfig{Level: level, Components: components}, sink), sink
+}
+
+func TestLevels(t *testing.T) {
+	l, sink := logged(config.TLogLevel{Info: true, Error: true}, map[string]config.TLogLevel{"Storage": {Debug: true}})
+	l.Component("Server").Info("server info")
+	l.Component("Server").Debug("server debug")
+	l.Component("Server").Error("server error")
+	l.Component("Storage").Info("storage info")
+	l.Component("Storage").Debug("storage debug")
+	l.Log(&TEntry{Level: Sys, Component: "Storage", Message: "storage sys"})
+	l.Log(&TEntry{Level: "trace", Message: "unknown level"})
+	got := make([]string, 0, len(sink.entries))
+	for _, e := range sink.entries {
+		got = append(got, e.Level+" "+e.Message)
+		if e.Time.IsZero() {
+			t.Errorf("%v has no time", e.Message)
+		}
+	}
+	want := []string{"info server info", "error server error", "debug storage debug", "sys storage sys"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("entries %q, want %q", got, want)
+	}
+
+	l.Configure(&config.TLogConfig{Level: config.TLogLevel{Debug: true}})
+	if !l.Enabled("Storage", Debug) || !l.Enabled("Server", Debug) || l.Enabled("Server", Info) {
+		t.Error("levels are not reconfigured")
+	}
+}
+
+func TestFields(t *testing.T) {
+	config.Secret("fields-secret")
+	l, sink := logged(config.TLogLevel{Info: true}, nil)
+	r := l.Component("Storage").With("table", "users").With("rows", 3)
+	r.Info("inserted %d rows with fields-secret", 3)
+	r.With("token", "fields-secret").Info("plain")
+	if len(sink.entries) != 2 {
+		t.Fatalf("entries %+v", sink.entries)
+	}
+	first, second := sink.entries[0], sink.entries[1]
+	if first.Message != "inserted 3 rows with ******" || first.Component != "Storage" {
+		t.Errorf("first entry %+v", first)
+	}
+	if want := (TFields{"table": "users", "rows": 3}); !reflect.DeepEqual(first.Fields, want) {
+		t.Errorf("fields %v, want %v", first.Fields, want)
+	}
+	if want := (TFields{"table": "users", "rows": 3, "token": "******"}); !reflect.DeepEqual(second.Fields, want) {
+		t.Errorf("fields %v, want %v", second.Fields, want)
+	}
+	if second.Message != "plain" {
+		t.Errorf("message without arguments %q", second.Message)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	l, sink := logged(config.TLogLevel{Debug: true}, nil)
+	l.Component("Server").Route("storage").Message("m-1").Trace("t-1").Debug("request")
+	if e := sink.entries[0]; e.Route != "storage" || e.MessageID != "m-1" || e.TraceID != "t-1" {
+		t.Errorf("entry %+v", e)
+	}
+
+	if err := dynamic.Tracing(&dynamic.TTraceConfig{Enabled: true, Arguments: true}); err != nil {
+		t.Fatal(err)
+	}
+	dynamic.TraceTo(l.Span)
+	defer func() {
+		dynamic.Tracing(nil)
+		dynamic.TraceTo(nil)
+	}()
+	fail := func(a, b int) (int, error) { return a + b, errors.New("overflow") }
+	if _, err := dynamic.CallE(fail, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.entries) != 2 {
+		t.Fatalf("entries %+v", sink.entries)
+	}
+	e := sink.entries[1]
+	if e.Component != Tracer || e.Level != Debug || e.Message != "call" || e.Time.IsZero() {
+		t.Errorf("span entry %+v", e)
+	}
+	for key, want := range map[string]interface{}{"error": true, "err": "overflow", "args": "1, 2", "results": "3, overflow"} {
+		if got := e.Fields[key]; got != want {
+			t.Errorf("span field %v = %v, want %v", key, got, want)
+		}
+	}
+	for _, key := range []string{"func", "caller", "duration"} {
+		if e.Fields[key] == "" || e.Fields[key] == nil {
+			t.Errorf("span field %v is empty", key)
+		}
+	}
+}
+
+func TestBus(t *testing.T) {
+	l, sink := logged(config.TLogLevel{Info: true}, nil)
+	l.bus(Info, "[Storage] component started")
+	l.bus(Info, "no component")
+	l.bus(Debug, "[Storage] disabled")
+	if len(sink.entries) != 2 {
+		t.Fatalf("entries %+v", sink.entries)
+	}
+	if e := sink.entries[0]; e.Component != "Storage" || e.Message != "component started" {
+		t.Errorf("entry %+v", e)
+	}
+	if e := sink.entries[1]; e.Component != "" || e.Message != "no component" {
+		t.Errorf("entry %+v", e)
+	}
+}
@@ -0,0 +1,262 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TWriterSink Приемник, пишущий строки в io.Writer
+type TWriterSink struct {
+	m      sync.Mutex
+	w      io.Writer
+	format string
+}
+
+// Writer (w: io.Writer, format: string) Приемник строк json или logfmt
+func Writer(w io.Writer, format string) *TWriterSink {
+	return &TWriterSink{w: w, format: format}
+}
+
+// Stdout (format: string) Приемник стандартного вывода
+func Stdout(format string) *TWriterSink {
+	return Writer(os.Stdout, format)
+}
+
+func (s *TWriterSink) Write(e *TEntry) error {
+	line, err := Format(e, s.format)
+	if err != nil {
+		return err
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	_, err = s.w.Write(line)
+	return err
+}
+
+func (s *TWriterSink) Close() error { return nil }
+
+// rotation Суффикс ротированного файла: время ротации
+const rotation = "20060102T150405.000000000"
+
+// TFileSink Файл с ротацией по размеру и времени
+type TFileSink struct {
+	m       sync.Mutex
+	path    string
+	format  string
+	maxSize int64
+	maxAge  time.Duration
+	backups int
+
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// File (path, format: string, maxSize: int64, maxAge: time.Duration, backups: int) Приемник файла.
+// Файл ротируется, когда превышает maxSize байт или открыт дольше maxAge; нулевые значения
+// отключают ротацию. Хранится не больше backups ротированных файлов, 0 - все.
+func File(path, format string, maxSize int64, maxAge time.Duration, backups int) (*TFileSink, error) {
+	s := &TFileSink{path: path, format: format, maxSize: maxSize, maxAge: maxAge, backups: backups}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *TFileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file, s.size, s.opened = f, info.Size(), info.ModTime()
+	if s.size == 0 {
+		s.opened = time.Now()
+	}
+	return nil
+}
+
+// rotate Переименовать текущий файл в path.<время> и открыть новый
+func (s *TFileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(s.path, s.path+"."+time.Now().Format(rotation)); err != nil {
+		return err
+	}
+	if s.backups > 0 {
+		rotated, err := s.rotated()
+		if err != nil {
+			return err
+		}
+		for len(rotated) > s.backups {
+			if err := os.Remove(rotated[0]); err != nil {
+				return err
+			}
+			rotated = rotated[1:]
+		}
+	}
+	return s.open()
+}
+
+// rotated Ротированные файлы от старых к новым; другие файлы вида path.* (app.log.bak) не трогаются
+func (s *TFileSink) rotated() ([]string, error) {
+	matches, err := filepath.Glob(s.path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	rotated := make([]string, 0, len(matches))
+	for _, m := range matches {
+		suffix := strings.TrimPrefix(m, s.path+".")
+		if _, err := time.Parse(rotation, suffix); err == nil && len(suffix) == len(rotation) {
+			rotated = append(rotated, m)
+		}
+	}
+	sort.Strings(rotated)
+	return rotated, nil
+}
+
+func (s *TFileSink) Write(e *TEntry) error {
+	line, err := Format(e, s.format)
+	if err != nil {
+		return err
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("%s is closed", s.path)
+	}
+	if s.size > 0 && ((s.maxSize > 0 && s.size+int64(len(line)) > s.maxSize) || (s.maxAge > 0 && time.Since(s.opened) > s.maxAge)) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+func (s *TFileSink) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// journalBuffer Число записей, ожидающих отправки в журнал
+const journalBuffer = 1024
+
+// iCollection Коллекция, в которую вставляются записи журнала (*mongo.Collection)
+type iCollection interface {
+	InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error)
+}
+
+// TJournalSink Коллекция журнала mongo. Записи вставляются в фоне, чтобы логирование
+// не ждало mongo; при переполнении буфера запись отбрасывается с ошибкой
+type TJournalSink struct {
+	m          sync.RWMutex
+	collection iCollection
+	timeout    time.Duration
+	entries    chan *TEntry
+	closed     bool
+	done       chan struct{}
+}
+
+// Journal (collection: *mongo.Collection) Приемник, записывающий документы в журнал
+func Journal(collection *mongo.Collection) *TJournalSink {
+	return journal(collection, journalBuffer)
+}
+
+func journal(collection iCollection, buffer int) *TJournalSink {
+	s := &TJournalSink{
+		collection: collection,
+		timeout:    5 * time.Second,
+		entries:    make(chan *TEntry, buffer),
+		done:       make(chan struct{}),
+	}
+	go s.insert()
+	return s
+}
+
+// insert Вставлять записи из буфера до закрытия приемника
+func (s *TJournalSink) insert() {
+	defer close(s.done)
+	for e := range s.entries {
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		if _, err := s.collection.InsertOne(ctx, e); err != nil {
+			fmt.Fprintf(os.Stderr, "[SYS] log sink: journal: %v\n", err)
+		}
+		cancel()
+	}
+}
+
+func (s *TJournalSink) Write(e *TEntry) error {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	if s.closed {
+		return fmt.Errorf("journal is closed")
+	}
+	entry := *e
+	select {
+	case s.entries <- &entry:
+		return nil
+	default:
+		return fmt.Errorf("journal buffer is full, entry dropped")
+	}
+}
+
+// Close Дождаться вставки записей из буфера
+func (s *TJournalSink) Close() error {
+	s.m.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.entries)
+	}
+	s.m.Unlock()
+	<-s.done
+	return nil
+}
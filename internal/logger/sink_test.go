@@ -0,0 +1,183 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package logger
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// names Имена файлов каталога
+func names(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := make([]string, 0, len(files))
+	for _, f := range files {
+		list = append(list, f.Name())
+	}
+	sort.Strings(list)
+	return list
+}
+
+func TestFileRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs", "app.log")
+	s, err := File(path, Logfmt, 40, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"app.log.bak", "app.log.lock", "app.log.20210307"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "logs", name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		// Каждая запись длиннее половины max_size, поэтому файл ротируется перед каждой следующей
+		if err := s.Write(&TEntry{Level: Info, Message: strings.Repeat("x", 20)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files := names(t, filepath.Join(dir, "logs"))
+	rotated := 0
+	for _, name := range files {
+		if strings.HasPrefix(name, "app.log.") && len(name) == len("app.log.")+len(rotation) {
+			rotated++
+		}
+	}
+	if rotated != 2 {
+		t.Errorf("rotated files %v, want 2", files)
+	}
+	for _, name := range []string{"app.log", "app.log.bak", "app.log.lock", "app.log.20210307"} {
+		if _, err := os.Stat(filepath.Join(dir, "logs", name)); err != nil {
+			t.Errorf("%v: %v", name, err)
+		}
+	}
+	buffer, err := ioutil.ReadFile(path)
+	if err != nil || string(buffer) != "level=info msg="+strings.Repeat("x", 20)+"\n" {
+		t.Errorf("current file %q, %v", buffer, err)
+	}
+	if err := s.Write(&TEntry{Level: Info}); err == nil {
+		t.Error("closed sink accepts entries")
+	}
+}
+
+func TestFileRotationByAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	s, err := File(path, JSON, 0, time.Millisecond, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Write(&TEntry{Level: Info, Message: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(&TEntry{Level: Info, Message: "second"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := s.Write(&TEntry{Level: Info, Message: "third"}); err != nil {
+		t.Fatal(err)
+	}
+	if files := names(t, dir); len(files) != 2 {
+		t.Fatalf("files %v, want the log and one rotated file", files)
+	}
+	buffer, _ := ioutil.ReadFile(path)
+	if !strings.Contains(string(buffer), "third") || strings.Contains(string(buffer), "first") {
+		t.Errorf("current file %s", buffer)
+	}
+}
+
+// tCollection Коллекция, сообщающая о начале вставки в started и ожидающая release
+type tCollection struct {
+	m        sync.Mutex
+	started  chan struct{}
+	release  chan struct{}
+	inserted []string
+}
+
+func (c *tCollection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	c.started <- struct{}{}
+	<-c.release
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.inserted = append(c.inserted, document.(*TEntry).Message)
+	return new(mongo.InsertOneResult), nil
+}
+
+func TestJournal(t *testing.T) {
+	c := &tCollection{started: make(chan struct{}, 8), release: make(chan struct{})}
+	s := journal(c, 2)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		write := func(m string) {
+			if err := s.Write(&TEntry{Message: m}); err != nil {
+				t.Errorf("Write(%v): %v", m, err)
+			}
+		}
+		// Первая запись ждет коллекцию, две следующие - в буфере
+		write("a")
+		<-c.started
+		write("b")
+		write("c")
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Write waits for mongo")
+	}
+	if err := s.Write(&TEntry{Message: "d"}); err == nil || !strings.Contains(err.Error(), "buffer is full") {
+		t.Errorf("Write to a full buffer = %v", err)
+	}
+	close(c.release)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(c.inserted, "") != "abc" {
+		t.Errorf("inserted %v, want a b c", c.inserted)
+	}
+	if err := s.Write(&TEntry{Message: "e"}); err == nil {
+		t.Error("closed journal accepts entries")
+	}
+	if err := s.Close(); err != nil {
+		t.Error(err)
+	}
+}
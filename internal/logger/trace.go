@@ -0,0 +1,50 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package logger
+
+import (
+	"strings"
+
+	"github.com/x-research-team/kernel/internal/dynamic"
+)
+
+// Tracer Компонент, от имени которого пишутся трассировки вызовов
+const Tracer = "Dynamic"
+
+// Span (s: *dynamic.TSpan) Записать трассировку вызова отладочной записью компонента Dynamic
+func (l *TLogger) Span(s *dynamic.TSpan) {
+	r := l.Component(Tracer).
+		With("func", s.Func).
+		With("caller", s.Caller).
+		With("duration", s.Duration.String()).
+		With("error", s.Error)
+	if s.Error {
+		r.With("err", s.Err)
+	}
+	if s.Args != nil {
+		r.With("args", strings.Join(s.Args, ", ")).With("results", strings.Join(s.Results, ", "))
+	}
+	r.entry.Time = s.Time
+	r.Debug("call")
+}
@@ -0,0 +1,375 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TTypes Допустимые типы значения (строка или массив строк в JSON)
+type TTypes []string
+
+func (t *TTypes) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = TTypes{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("type must be a string or an array of strings")
+	}
+	*t = many
+	return nil
+}
+
+// TSchema Документ JSON Schema (поддерживаемое подмножество draft-07)
+type TSchema struct {
+	Title                string              `json:"title,omitempty"`
+	Description          string              `json:"description,omitempty"`
+	Type                 TTypes              `json:"type,omitempty"`
+	Enum                 []interface{}       `json:"enum,omitempty"`
+	Const                *json.RawMessage    `json:"const,omitempty"`
+	Properties           map[string]*TSchema `json:"properties,omitempty"`
+	Required             []string            `json:"required,omitempty"`
+	AdditionalProperties *TSchema            `json:"additionalProperties,omitempty"`
+	Items                *TSchema            `json:"items,omitempty"`
+	MinItems             *int                `json:"minItems,omitempty"`
+	MaxItems             *int                `json:"maxItems,omitempty"`
+	MinLength            *int                `json:"minLength,omitempty"`
+	MaxLength            *int                `json:"maxLength,omitempty"`
+	Pattern              string              `json:"pattern,omitempty"`
+	Minimum              *float64            `json:"minimum,omitempty"`
+	Maximum              *float64            `json:"maximum,omitempty"`
+	AllOf                []*TSchema          `json:"allOf,omitempty"`
+	AnyOf                []*TSchema          `json:"anyOf,omitempty"`
+	OneOf                []*TSchema          `json:"oneOf,omitempty"`
+
+	boolean *bool
+	pattern *regexp.Regexp
+}
+
+type tSchema TSchema
+
+func (s *TSchema) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		s.boolean = &b
+		return nil
+	}
+	return json.Unmarshal(data, (*tSchema)(s))
+}
+
+// Parse Разобрать и скомпилировать схему
+func Parse(data []byte) (*TSchema, error) {
+	s := new(TSchema)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if err := s.compile(""); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *TSchema) compile(pointer string) error {
+	if s == nil || s.boolean != nil {
+		return nil
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("%s/pattern: %v", pointer, err)
+		}
+		s.pattern = re
+	}
+	for k, p := range s.Properties {
+		if err := p.compile(pointer + "/properties/" + escape(k)); err != nil {
+			return err
+		}
+	}
+	if err := s.AdditionalProperties.compile(pointer + "/additionalProperties"); err != nil {
+		return err
+	}
+	if err := s.Items.compile(pointer + "/items"); err != nil {
+		return err
+	}
+	for name, list := range map[string][]*TSchema{"allOf": s.AllOf, "anyOf": s.AnyOf, "oneOf": s.OneOf} {
+		for i, p := range list {
+			if err := p.compile(fmt.Sprintf("%s/%s/%d", pointer, name, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// TError Ошибка валидации: JSON pointer на значение и причина
+type TError struct {
+	Pointer string `json:"pointer"`
+	Reason  string `json:"reason"`
+}
+
+func (e TError) Error() string {
+	if e.Pointer == "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("%s: %s", e.Pointer, e.Reason)
+}
+
+// TErrors Набор ошибок валидации
+type TErrors []TError
+
+func (errs TErrors) Error() string {
+	s := make([]string, 0, len(errs))
+	for _, e := range errs {
+		s = append(s, e.Error())
+	}
+	return strings.Join(s, "; ")
+}
+
+// ValidateJSON Проверить JSON документ по схеме
+func (s *TSchema) ValidateJSON(data []byte) error {
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return TErrors{{Pointer: "", Reason: fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+	return s.Validate(v)
+}
+
+// Validate Проверить значение (результат json.Unmarshal) по схеме
+func (s *TSchema) Validate(v interface{}) error {
+	errs := s.validate("", v)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (s *TSchema) validate(pointer string, v interface{}) TErrors {
+	var errs TErrors
+	if s == nil {
+		return errs
+	}
+	if s.boolean != nil {
+		if !*s.boolean {
+			errs = append(errs, TError{pointer, "value is not allowed"})
+		}
+		return errs
+	}
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, TError{pointer, fmt.Sprintf(format, args...)})
+	}
+	if len(s.Type) > 0 && !s.Type.match(v) {
+		fail("expected %s, got %s", strings.Join(s.Type, " or "), typeOf(v))
+		return errs
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if equal(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value must be one of %v", s.Enum)
+		}
+	}
+	if s.Const != nil {
+		var c interface{}
+		if err := json.Unmarshal(*s.Const, &c); err == nil && !equal(c, v) {
+			fail("value must be %s", string(*s.Const))
+		}
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := t[name]; !ok {
+				errs = append(errs, TError{pointer + "/" + escape(name), "required property is missing"})
+			}
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := pointer + "/" + escape(k)
+			if p, ok := s.Properties[k]; ok {
+				errs = append(errs, p.validate(child, t[k])...)
+				continue
+			}
+			if s.AdditionalProperties != nil {
+				if b := s.AdditionalProperties.boolean; b != nil && !*b {
+					errs = append(errs, TError{child, "unknown property"})
+					continue
+				}
+				errs = append(errs, s.AdditionalProperties.validate(child, t[k])...)
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(t) < *s.MinItems {
+			fail("expected at least %d items, got %d", *s.MinItems, len(t))
+		}
+		if s.MaxItems != nil && len(t) > *s.MaxItems {
+			fail("expected at most %d items, got %d", *s.MaxItems, len(t))
+		}
+		for i := range t {
+			errs = append(errs, s.Items.validate(pointer+"/"+strconv.Itoa(i), t[i])...)
+		}
+	case string:
+		n := utf8.RuneCountInString(t)
+		if s.MinLength != nil && n < *s.MinLength {
+			fail("expected at least %d characters, got %d", *s.MinLength, n)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("expected at most %d characters, got %d", *s.MaxLength, n)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(t) {
+			fail("value does not match pattern %q", s.Pattern)
+		}
+	case json.Number, float64:
+		f := number(t)
+		if s.Minimum != nil && f < *s.Minimum {
+			fail("value must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			fail("value must be <= %v", *s.Maximum)
+		}
+	}
+	for _, p := range s.AllOf {
+		errs = append(errs, p.validate(pointer, v)...)
+	}
+	if len(s.AnyOf) > 0 {
+		matched := false
+		for _, p := range s.AnyOf {
+			if len(p.validate(pointer, v)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("value does not match any of the allowed schemas")
+		}
+	}
+	if len(s.OneOf) > 0 {
+		matched := 0
+		for _, p := range s.OneOf {
+			if len(p.validate(pointer, v)) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			fail("value must match exactly one schema, matched %d", matched)
+		}
+	}
+	return errs
+}
+
+func (t TTypes) match(v interface{}) bool {
+	actual := typeOf(v)
+	for _, expected := range t {
+		switch {
+		case expected == actual:
+			return true
+		case expected == "number" && actual == "integer":
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number, float64:
+		if f := number(t); f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func number(v interface{}) float64 {
+	switch t := v.(type) {
+	case json.Number:
+		f, _ := t.Float64()
+		return f
+	case float64:
+		return t
+	}
+	return math.NaN()
+}
+
+func equal(a, b interface{}) bool {
+	x, errX := json.Marshal(normalize(a))
+	y, errY := json.Marshal(normalize(b))
+	return errX == nil && errY == nil && bytes.Equal(x, y)
+}
+
+// normalize Заменить json.Number на float64 на любой глубине, чтобы 1.0 и 1 совпадали
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		return number(t)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			m[k] = normalize(e)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(t))
+		for i, e := range t {
+			list[i] = normalize(e)
+		}
+		return list
+	}
+	return v
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
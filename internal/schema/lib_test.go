@@ -0,0 +1,165 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// problems Ошибки валидации документа по схеме строками
+func problems(t *testing.T, schema, document string) []string {
+	t.Helper()
+	s, err := Parse([]byte(schema))
+	if err != nil {
+		t.Fatalf("Parse(%s): %v", schema, err)
+	}
+	err = s.ValidateJSON([]byte(document))
+	if err == nil {
+		return nil
+	}
+	errs, ok := err.(TErrors)
+	if !ok {
+		t.Fatalf("ValidateJSON returned %T, want TErrors", err)
+	}
+	list := make([]string, 0, len(errs))
+	for _, e := range errs {
+		list = append(list, e.Error())
+	}
+	return list
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name     string
+		schema   string
+		document string
+		want     []string
+	}{
+		{"type", `{"type":"string"}`, `"a"`, nil},
+		{"type mismatch", `{"type":"string"}`, `1`, []string{"expected string, got integer"}},
+		{"type list", `{"type":["string","null"]}`, `null`, nil},
+		{"type list mismatch", `{"type":["string","null"]}`, `true`, []string{"expected string or null, got boolean"}},
+		{"integer", `{"type":"integer"}`, `2.0`, nil},
+		{"not integer", `{"type":"integer"}`, `2.5`, []string{"expected integer, got number"}},
+		{"integer is number", `{"type":"number"}`, `2`, nil},
+		{"object and array", `{"type":"object"}`, `[]`, []string{"expected object, got array"}},
+
+		{"enum", `{"enum":["a",1]}`, `1.0`, nil},
+		{"enum mismatch", `{"enum":["a",1]}`, `"1"`, []string{`value must be one of [a 1]`}},
+		{"enum nested number", `{"enum":[{"a":[1]}]}`, `{"a":[1.0]}`, nil},
+		{"enum nested mismatch", `{"enum":[{"a":1}]}`, `{"a":"1"}`, []string{`value must be one of [map[a:1]]`}},
+		{"const", `{"const":{"a":1}}`, `{"a":1.0}`, nil},
+		{"const mismatch", `{"const":"x"}`, `"y"`, []string{`value must be "x"`}},
+
+		{"required", `{"required":["a","b"]}`, `{"a":1}`, []string{"/b: required property is missing"}},
+		{"required not object", `{"required":["a"]}`, `1`, nil},
+		{"properties", `{"properties":{"a":{"type":"string"}}}`, `{"a":1,"b":1}`, []string{"/a: expected string, got integer"}},
+		{"additional false", `{"properties":{"a":{}},"additionalProperties":false}`, `{"a":1,"b":1,"c":1}`,
+			[]string{"/b: unknown property", "/c: unknown property"}},
+		{"additional schema", `{"additionalProperties":{"type":"integer"}}`, `{"a":1,"b":"x"}`, []string{"/b: expected integer, got string"}},
+
+		{"items", `{"items":{"type":"integer"}}`, `[1,"a",2,null]`,
+			[]string{"/1: expected integer, got string", "/3: expected integer, got null"}},
+		{"min items", `{"minItems":2}`, `[1]`, []string{"expected at least 2 items, got 1"}},
+		{"max items", `{"maxItems":1}`, `[1,2]`, []string{"expected at most 1 items, got 2"}},
+		{"min length", `{"minLength":2}`, `"ж"`, []string{"expected at least 2 characters, got 1"}},
+		{"max length", `{"maxLength":2}`, `"жжж"`, []string{"expected at most 2 characters, got 3"}},
+		{"minimum", `{"minimum":1}`, `0.5`, []string{"value must be >= 1"}},
+		{"maximum", `{"maximum":1}`, `2`, []string{"value must be <= 1"}},
+		{"bounds", `{"minimum":1,"maximum":1}`, `1`, nil},
+		{"pattern", `{"pattern":"^a+$"}`, `"aa"`, nil},
+		{"pattern mismatch", `{"pattern":"^a+$"}`, `"ab"`, []string{`value does not match pattern "^a+$"`}},
+
+		{"all of", `{"allOf":[{"type":"integer"},{"minimum":2}]}`, `1`, []string{"value must be >= 2"}},
+		{"any of", `{"anyOf":[{"type":"string"},{"minimum":2}]}`, `3`, nil},
+		{"any of mismatch", `{"anyOf":[{"type":"string"},{"minimum":2}]}`, `1`,
+			[]string{"value does not match any of the allowed schemas"}},
+		{"one of", `{"oneOf":[{"type":"string"},{"type":"integer"}]}`, `1`, nil},
+		{"one of none", `{"oneOf":[{"type":"string"},{"type":"null"}]}`, `1`,
+			[]string{"value must match exactly one schema, matched 0"}},
+		{"one of both", `{"oneOf":[{"type":"number"},{"type":"integer"}]}`, `1`,
+			[]string{"value must match exactly one schema, matched 2"}},
+
+		{"true schema", `true`, `{"a":1}`, nil},
+		{"false schema", `false`, `1`, []string{"value is not allowed"}},
+		{"false property", `{"properties":{"a":false}}`, `{"a":1}`, []string{"/a: value is not allowed"}},
+
+		{"nested pointer", `{"properties":{"list":{"items":{"required":["id"]}}}}`, `{"list":[{"id":1},{}]}`,
+			[]string{"/list/1/id: required property is missing"}},
+		{"escaped pointer", `{"additionalProperties":false}`, `{"a/b~c":1}`, []string{"/a~1b~0c: unknown property"}},
+		{"invalid JSON", `{}`, `{`, []string{"invalid JSON: unexpected EOF"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := problems(t, c.schema, c.document); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestValidateValue(t *testing.T) {
+	s, err := Parse([]byte(`{"properties":{"n":{"enum":[1,2]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Значения после json.Unmarshal без UseNumber: числа уже float64
+	var v interface{}
+	if err := json.Unmarshal([]byte(`{"n":2}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(v); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	if err := s.Validate(map[string]interface{}{"n": 3.0}); err == nil || err.Error() != "/n: value must be one of [1 2]" {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name   string
+		schema string
+		err    string
+	}{
+		{"types", `{"type":["string",1]}`, "type must be a string or an array of strings"},
+		{"type", `{"type":1}`, "type must be a string or an array of strings"},
+		{"pattern", `{"pattern":"("}`, "/pattern: error parsing regexp"},
+		{"nested pattern", `{"properties":{"a/b":{"items":{"pattern":"["}}}}`, "/properties/a~1b/items/pattern: "},
+		{"additional pattern", `{"additionalProperties":{"pattern":"["}}`, "/additionalProperties/pattern: "},
+		{"one of pattern", `{"oneOf":[{},{"pattern":"["}]}`, "/oneOf/1/pattern: "},
+		{"not a schema", `"string"`, "cannot unmarshal string"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Parse([]byte(c.schema))
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("Parse(%s) = %v, want %q", c.schema, err, c.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package schema
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// TRegistry Реестр схем сообщений по паре (route, command)
+type TRegistry struct {
+	m       sync.RWMutex
+	schemas map[string]*TSchema
+}
+
+// Registry Создать пустой реестр схем
+func Registry() *TRegistry {
+	return &TRegistry{schemas: make(map[string]*TSchema)}
+}
+
+func key(route, command string) string {
+	return route + "/" + command
+}
+
+// Load (dir: string) Загрузить схемы из каталога вида <dir>/<route>/<command>.json
+func Load(dir string) (*TRegistry, error) {
+	r := Registry()
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return r, nil
+	}
+	var errs []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) != 2 {
+			errs = append(errs, fmt.Sprintf("%s: expected <route>/<command>.json", path))
+			return nil
+		}
+		buffer, err := ioutil.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", path, err))
+			return nil
+		}
+		s, err := Parse(buffer)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", path, err))
+			return nil
+		}
+		r.Register(parts[0], strings.TrimSuffix(parts[1], ".json"), s)
+		return nil
+	})
+	if err != nil {
+		return r, err
+	}
+	if len(errs) != 0 {
+		return r, fmt.Errorf("[Schema] %v", strings.Join(errs, ", "))
+	}
+	return r, nil
+}
+
+// Register Зарегистрировать схему для пары (route, command)
+func (r *TRegistry) Register(route, command string, s *TSchema) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.schemas[key(route, command)] = s
+}
+
+// Lookup Найти схему для пары (route, command)
+func (r *TRegistry) Lookup(route, command string) (*TSchema, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.m.RLock()
+	defer r.m.RUnlock()
+	s, ok := r.schemas[key(route, command)]
+	return s, ok
+}
+
+// Validate Проверить данные сообщения; сообщения без схемы пропускаются
+func (r *TRegistry) Validate(route, command string, data []byte) error {
+	s, ok := r.Lookup(route, command)
+	if !ok {
+		return nil
+	}
+	return s.ValidateJSON(data)
+}
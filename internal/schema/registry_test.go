@@ -0,0 +1,107 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package schema
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// files Создать файлы во временном каталоге
+func files(t *testing.T, list map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range list {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := files(t, map[string]string{
+		"storage/insert.json": `{"required":["table"]}`,
+		"storage/readme.md":   `not a schema`,
+	})
+	r, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Lookup("storage", "insert"); !ok {
+		t.Fatal("storage/insert is not registered")
+	}
+	if err := r.Validate("storage", "insert", []byte(`{}`)); err == nil || err.Error() != "/table: required property is missing" {
+		t.Errorf("Validate = %v", err)
+	}
+	if err := r.Validate("storage", "select", []byte(`{}`)); err != nil {
+		t.Errorf("message without a schema is rejected: %v", err)
+	}
+	var none *TRegistry
+	if _, ok := none.Lookup("storage", "insert"); ok {
+		t.Error("nil registry finds a schema")
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	r, err := Load(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || r == nil {
+		t.Fatalf("Load = %v, %v", r, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := files(t, map[string]string{
+		"top.json":              `{}`,
+		"storage/deep/cmd.json": `{}`,
+		"storage/broken.json":   `{`,
+		"storage/pattern.json":  `{"pattern":"["}`,
+		"storage/valid.json":    `{}`,
+	})
+	r, err := Load(dir)
+	if err == nil {
+		t.Fatal("Load accepts a malformed layout")
+	}
+	for _, want := range []string{
+		"[Schema] ",
+		filepath.Join(dir, "top.json") + ": expected <route>/<command>.json",
+		filepath.Join(dir, "storage", "deep", "cmd.json") + ": expected <route>/<command>.json",
+		filepath.Join(dir, "storage", "broken.json") + ": unexpected end of JSON input",
+		filepath.Join(dir, "storage", "pattern.json") + ": /pattern: ",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+	if _, ok := r.Lookup("storage", "valid"); !ok {
+		t.Error("valid schemas are not loaded next to broken ones")
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package tenant
+
+import (
+	"github.com/x-research-team/contract"
+)
+
+// Shared Пустое пространство имен: компоненты и сообщения без арендатора
+const Shared = ""
+
+// ITenantMessage Сообщение, привязанное к арендатору
+type ITenantMessage interface {
+	contract.IMessage
+	Tenant() string
+}
+
+// TMessage Сообщение ядра с идентификатором арендатора
+type TMessage struct {
+	contract.IMessage
+	tenant string
+}
+
+// Tenant Арендатор сообщения
+func (message TMessage) Tenant() string {
+	return message.tenant
+}
+
+// Message Привязать сообщение к арендатору
+func Message(tenant string, m contract.IMessage) contract.IMessage {
+	if tenant == Shared {
+		return m
+	}
+	return &TMessage{IMessage: m, tenant: tenant}
+}
+
+// Of Арендатор сообщения или Shared
+func Of(m contract.IMessage) string {
+	if t, ok := m.(ITenantMessage); ok {
+		return t.Tenant()
+	}
+	return Shared
+}
+
+// IService Сервис, умеющий регистрировать компоненты арендатора
+type IService interface {
+	contract.IService
+	AddTenantComponent(tenant string, c contract.IComponent)
+}
+
+type service struct {
+	IService
+	tenant string
+}
+
+func (s *service) AddComponent(c contract.IComponent) {
+	s.IService.AddTenantComponent(s.tenant, c)
+}
+
+// Bind Зарегистрировать компоненты модулей только для арендатора
+func Bind(tenant string, modules ...contract.KernelModule) contract.KernelModule {
+	return func(s contract.IService) {
+		ts, ok := s.(IService)
+		for _, m := range modules {
+			if !ok || tenant == Shared {
+				m(s)
+				continue
+			}
+			m(&service{IService: ts, tenant: tenant})
+		}
+	}
+}
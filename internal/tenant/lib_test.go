@@ -0,0 +1,83 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package tenant
+
+import (
+	"testing"
+
+	"github.com/x-research-team/contract"
+)
+
+// tComponent Компонент, различимый по имени
+type tComponent struct {
+	contract.IComponent
+	name string
+}
+
+// tService Сервис, запоминающий владельцев добавленных компонентов
+type tService struct {
+	IService
+	owners map[string]string
+}
+
+func (s *tService) AddComponent(c contract.IComponent) {
+	s.owners[c.(*tComponent).name] = Shared
+}
+
+func (s *tService) AddTenantComponent(tenant string, c contract.IComponent) {
+	s.owners[c.(*tComponent).name] = tenant
+}
+
+// module Модуль ядра, добавляющий компонент name
+func module(name string) contract.KernelModule {
+	return func(s contract.IService) {
+		s.AddComponent(&tComponent{name: name})
+	}
+}
+
+func TestBind(t *testing.T) {
+	s := &tService{owners: make(map[string]string)}
+	Bind("acme", module("billing"), module("reports"))(s)
+	Bind(Shared, module("server"))(s)
+	module("storage")(s)
+	want := map[string]string{"billing": "acme", "reports": "acme", "server": Shared, "storage": Shared}
+	for name, tenant := range want {
+		if owner, ok := s.owners[name]; !ok || owner != tenant {
+			t.Errorf("component %v is owned by %q, want %q", name, owner, tenant)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	var m contract.IMessage = &TMessage{}
+	if Message(Shared, m) != m {
+		t.Error("shared message is wrapped")
+	}
+	if got := Of(Message("acme", m)); got != "acme" {
+		t.Errorf("Of = %q, want acme", got)
+	}
+	if got := Of(m); got != Shared {
+		t.Errorf("Of(plain message) = %q, want shared", got)
+	}
+}
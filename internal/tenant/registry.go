@@ -0,0 +1,160 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package tenant
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// TConfig Настройки арендатора
+type TConfig struct {
+	Name      string            `json:"name"`
+	Tokens    []string          `json:"tokens"`              // Токены доступа на входе сервера
+	Allow     []string          `json:"allow,omitempty"`     // Арендаторы, в компоненты которых разрешено маршрутизировать
+	Storage   map[string]string `json:"storage,omitempty"`   // Соединение хранилища => соединение арендатора
+	Functions []string          `json:"functions,omitempty"` // Функции реестра, доступные командам ядра (шаблоны filepath.Match)
+}
+
+// TConfigs Настройки арендаторов
+type TConfigs []TConfig
+
+// TRegistry Реестр арендаторов
+type TRegistry struct {
+	m       sync.RWMutex
+	tenants map[string]*TConfig
+	tokens  map[string]string
+}
+
+// Registry Создать реестр арендаторов
+func Registry(configs TConfigs) (*TRegistry, error) {
+	r := &TRegistry{
+		tenants: make(map[string]*TConfig),
+		tokens:  make(map[string]string),
+	}
+	for i := range configs {
+		c := configs[i]
+		if c.Name == Shared {
+			return nil, fmt.Errorf("[Tenant] name of tenant can not be empty")
+		}
+		if _, ok := r.tenants[c.Name]; ok {
+			return nil, fmt.Errorf("[Tenant] duplicate tenant (%v)", c.Name)
+		}
+		for _, pattern := range c.Functions {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("[Tenant] function pattern (%v) of %v: %v", pattern, c.Name, err)
+			}
+		}
+		for _, token := range c.Tokens {
+			if owner, ok := r.tokens[token]; ok {
+				return nil, fmt.Errorf("[Tenant] token of %v is already used by %v", c.Name, owner)
+			}
+			r.tokens[token] = c.Name
+		}
+		r.tenants[c.Name] = &c
+	}
+	return r, nil
+}
+
+// Enabled Включена ли изоляция арендаторов
+func (r *TRegistry) Enabled() bool {
+	if r == nil {
+		return false
+	}
+	r.m.RLock()
+	defer r.m.RUnlock()
+	return len(r.tenants) != 0
+}
+
+// Authenticate Определить арендатора по токену доступа
+func (r *TRegistry) Authenticate(token string) (string, bool) {
+	if !r.Enabled() {
+		return Shared, true
+	}
+	r.m.RLock()
+	defer r.m.RUnlock()
+	tenant, ok := r.tokens[token]
+	return tenant, ok
+}
+
+// Allowed Можно ли доставить сообщение арендатора from в компонент арендатора to
+func (r *TRegistry) Allowed(from, to string) bool {
+	if to == Shared || from == to {
+		return true
+	}
+	if r == nil {
+		return false
+	}
+	r.m.RLock()
+	defer r.m.RUnlock()
+	c, ok := r.tenants[from]
+	if !ok {
+		return false
+	}
+	for _, allowed := range c.Allow {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Callable Можно ли арендатору вызывать функцию реестра; общие сообщения вызывают любые функции
+func (r *TRegistry) Callable(tenant, function string) bool {
+	if tenant == Shared {
+		return true
+	}
+	if r == nil {
+		return false
+	}
+	r.m.RLock()
+	defer r.m.RUnlock()
+	c, ok := r.tenants[tenant]
+	if !ok {
+		return false
+	}
+	for _, pattern := range c.Functions {
+		if ok, _ := filepath.Match(pattern, function); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Connection Соединение хранилища для арендатора. Арендатор без своего соединения для name
+// получает false: общее соединение хранит данные всех арендаторов
+func (r *TRegistry) Connection(tenant, name string) (string, bool) {
+	if tenant == Shared || !r.Enabled() {
+		return name, true
+	}
+	r.m.RLock()
+	defer r.m.RUnlock()
+	c, ok := r.tenants[tenant]
+	if !ok {
+		return "", false
+	}
+	mapped, ok := c.Storage[name]
+	return mapped, ok
+}
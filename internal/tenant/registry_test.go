@@ -0,0 +1,149 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+package tenant
+
+import "testing"
+
+func TestCallable(t *testing.T) {
+	r, err := Registry(TConfigs{
+		{Name: "acme", Functions: []string{"sum", "billing.*"}},
+		{Name: "globex"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		tenant, function string
+		want             bool
+	}{
+		{Shared, "anything", true},
+		{"acme", "sum", true},
+		{"acme", "billing.invoice", true},
+		{"acme", "billing", false},
+		{"acme", "summary", false},
+		{"globex", "sum", false},
+		{"unknown", "sum", false},
+	}
+	for _, c := range cases {
+		if got := r.Callable(c.tenant, c.function); got != c.want {
+			t.Errorf("Callable(%q, %q) = %v, want %v", c.tenant, c.function, got, c.want)
+		}
+	}
+	var disabled *TRegistry
+	if disabled.Callable("acme", "sum") || !disabled.Callable(Shared, "sum") {
+		t.Error("nil registry allows a tenant or rejects shared messages")
+	}
+	if _, err := Registry(TConfigs{{Name: "acme", Functions: []string{"["}}}); err == nil {
+		t.Error("malformed function pattern is accepted")
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	r, err := Registry(TConfigs{
+		{Name: "acme", Allow: []string{"billing"}},
+		{Name: "billing"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{Shared, Shared, true},
+		{"acme", Shared, true},
+		{"acme", "acme", true},
+		{"acme", "billing", true},
+		{"billing", "acme", false},
+		{Shared, "acme", false},
+		{"unknown", "acme", false},
+		{"unknown", "unknown", true},
+	}
+	for _, c := range cases {
+		if got := r.Allowed(c.from, c.to); got != c.want {
+			t.Errorf("Allowed(%q, %q) = %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+	var disabled *TRegistry
+	if disabled.Allowed("acme", "billing") || !disabled.Allowed("acme", Shared) {
+		t.Error("nil registry routes across tenants or rejects shared components")
+	}
+}
+
+func TestConnection(t *testing.T) {
+	r, err := Registry(TConfigs{
+		{Name: "acme", Storage: map[string]string{"journal": "acme-journal"}},
+		{Name: "globex"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		tenant, name string
+		want         string
+		ok           bool
+	}{
+		{Shared, "journal", "journal", true},
+		{"acme", "journal", "acme-journal", true},
+		{"acme", "signal", "", false},
+		{"globex", "journal", "", false},
+		{"unknown", "journal", "", false},
+	}
+	for _, c := range cases {
+		if got, ok := r.Connection(c.tenant, c.name); got != c.want || ok != c.ok {
+			t.Errorf("Connection(%q, %q) = %q, %v, want %q, %v", c.tenant, c.name, got, ok, c.want, c.ok)
+		}
+	}
+	for _, disabled := range []*TRegistry{nil, new(TRegistry)} {
+		if got, ok := disabled.Connection("acme", "journal"); got != "journal" || !ok {
+			t.Errorf("registry without tenants: Connection = %q, %v", got, ok)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	r, err := Registry(TConfigs{{Name: "acme", Tokens: []string{"secret"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tenant, ok := r.Authenticate("secret"); tenant != "acme" || !ok {
+		t.Errorf("Authenticate(secret) = %q, %v", tenant, ok)
+	}
+	if _, ok := r.Authenticate("other"); ok {
+		t.Error("unknown token is accepted")
+	}
+	var disabled *TRegistry
+	if tenant, ok := disabled.Authenticate(""); tenant != Shared || !ok {
+		t.Errorf("nil registry: Authenticate = %q, %v", tenant, ok)
+	}
+	for _, configs := range []TConfigs{
+		{{Name: Shared}},
+		{{Name: "acme"}, {Name: "acme"}},
+		{{Name: "acme", Tokens: []string{"t"}}, {Name: "globex", Tokens: []string{"t"}}},
+	} {
+		if _, err := Registry(configs); err == nil {
+			t.Errorf("Registry(%+v) is accepted", configs)
+		}
+	}
+}
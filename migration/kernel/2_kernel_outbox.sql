@@ -0,0 +1,40 @@
+/*
+ *   Copyright (c) 2021 Adel Urazov
+ *   All rights reserved.
+
+ *   Permission is hereby granted, free of charge, to any person obtaining a copy
+ *   of this software and associated documentation files (the "Software"), to deal
+ *   in the Software without restriction, including without limitation the rights
+ *   to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ *   copies of the Software, and to permit persons to whom the Software is
+ *   furnished to do so, subject to the following conditions:
+ 
+ *   The above copyright notice and this permission notice shall be included in all
+ *   copies or substantial portions of the Software.
+ 
+ *   THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ *   IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ *   FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ *   AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ *   LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ *   OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ *   SOFTWARE.
+ */
+
+CREATE TABLE IF NOT EXISTS kernel_outbox (
+	id VARCHAR(36) NOT NULL PRIMARY KEY,
+	tenant VARCHAR(255) NOT NULL DEFAULT '',
+	route VARCHAR(255) NOT NULL,
+	command VARCHAR(255) NOT NULL,
+	data LONGTEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	created_at BIGINT NOT NULL,
+	available_at BIGINT NOT NULL,
+	delivered_at BIGINT NULL,
+	last_error TEXT NULL
+);
+
+CREATE TABLE IF NOT EXISTS kernel_inbox (
+	id VARCHAR(36) NOT NULL PRIMARY KEY,
+	received_at BIGINT NOT NULL
+);